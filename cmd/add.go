@@ -15,7 +15,7 @@ import (
 var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "新しい取引を記録します",
-	Long:  `購入 (buy) または売却 (sell) の取引を記録します`,
+	Long:  `購入 (buy), 売却 (sell), 分配金 (distribution), 手数料 (fee), 引き出し (withdrawal) の取引を記録します`,
 }
 
 // buyCmd represents the buy command
@@ -64,6 +64,67 @@ var sellCmd = &cobra.Command{
 	},
 }
 
+// distributionCmd represents the distribution command
+var distributionCmd = &cobra.Command{
+	Use:   "distribution",
+	Short: "分配金の受け取りを追加します",
+	Long:  `受け取った分配金の金額 (amount) を記録します`,
+	Run: func(cmd *cobra.Command, args []string) {
+		amount, _ := cmd.Flags().GetInt("amount")
+		if amount <= 0 {
+			fmt.Fprintln(os.Stderr, "--amount を指定する必要があります")
+			os.Exit(1)
+		}
+
+		if err := data.AddTransaction("distribution", amount, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "取引の追加に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("分配金を追加しました: 金額: %d\n", amount)
+	},
+}
+
+// feeCmd represents the fee command
+var feeCmd = &cobra.Command{
+	Use:   "fee",
+	Short: "手数料の支払いを追加します",
+	Long:  `支払った手数料の金額 (amount) を記録します`,
+	Run: func(cmd *cobra.Command, args []string) {
+		amount, _ := cmd.Flags().GetInt("amount")
+		if amount <= 0 {
+			fmt.Fprintln(os.Stderr, "--amount を指定する必要があります")
+			os.Exit(1)
+		}
+
+		if err := data.AddTransaction("fee", amount, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "取引の追加に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("手数料を追加しました: 金額: %d\n", amount)
+	},
+}
+
+// withdrawalCmd represents the withdrawal command
+var withdrawalCmd = &cobra.Command{
+	Use:   "withdrawal",
+	Short: "生活費などの引き出しを追加します",
+	Long: `売却代金や分配金の現金残高から引き出した金額 (amount) を記録します
+現金残高は status や bucket 戦略で使われます`,
+	Run: func(cmd *cobra.Command, args []string) {
+		amount, _ := cmd.Flags().GetInt("amount")
+		if amount <= 0 {
+			fmt.Fprintln(os.Stderr, "--amount を指定する必要があります")
+			os.Exit(1)
+		}
+
+		if err := data.AddTransaction("withdrawal", amount, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "取引の追加に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("引き出しを追加しました: 金額: %d\n", amount)
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(addCmd)
 
@@ -79,10 +140,19 @@ func init() {
 
 	addCmd.AddCommand(buyCmd)
 	addCmd.AddCommand(sellCmd)
+	addCmd.AddCommand(distributionCmd)
+	addCmd.AddCommand(feeCmd)
+	addCmd.AddCommand(withdrawalCmd)
 
 	buyCmd.Flags().Int("amount", 0, "取引金額 (円)")
 	buyCmd.Flags().Int("units", 0, "取引口数")
 
 	sellCmd.Flags().Int("amount", 0, "取引金額 (円)")
 	sellCmd.Flags().Int("units", 0, "取引口数")
+
+	distributionCmd.Flags().Int("amount", 0, "分配金額 (円)")
+
+	feeCmd.Flags().Int("amount", 0, "手数料 (円)")
+
+	withdrawalCmd.Flags().Int("amount", 0, "引き出した金額 (円)")
 }
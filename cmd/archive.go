@@ -0,0 +1,258 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"kk-invest/internal/archive"
+	"kk-invest/internal/config"
+	"kk-invest/internal/data"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// パスフレーズを環境変数から渡す場合の変数名
+const passphraseEnv = "KK_INVEST_PASSPHRASE"
+
+// 書類一式の中でのファイル名
+const (
+	archiveDBName     = "invest.db"
+	archiveConfigName = "config.json"
+)
+
+// archiveCmd represents the archive command
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "持ち出し用の書類一式を作成・展開します",
+	Long: `データベース, 設定ファイル, チェックサム付きの manifest を1つのファイルにまとめます
+--encrypt を指定するとパスフレーズで暗号化 (AES-256-GCM) し, クラウドストレージ等に安全に保管できます
+パスフレーズは端末から入力するか, 環境変数 ` + passphraseEnv + ` で指定します`,
+}
+
+// archiveCreateCmd represents the archive create command
+var archiveCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "書類一式を作成します",
+	Long:  `データベースのスナップショット, 設定ファイル, manifest をまとめた書類一式を作成します`,
+	Run: func(cmd *cobra.Command, args []string) {
+		encrypt, _ := cmd.Flags().GetBool("encrypt")
+		output, _ := cmd.Flags().GetString("output")
+		if output == "" {
+			ext := ".tar.gz"
+			if encrypt {
+				ext = ".kkarc"
+			}
+			output = "kk-invest-" + time.Now().Format("20060102-150405") + ext
+		}
+
+		// 一貫したスナップショットを一時ディレクトリに作成してから読み込む
+		tmpDir, err := os.MkdirTemp("", "kk-invest-archive")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "一時ディレクトリの作成に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(tmpDir)
+		snapshot, err := data.Backup(tmpDir, "archive")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "データベースのスナップショットに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		files := make(map[string][]byte)
+		if files[archiveDBName], err = os.ReadFile(snapshot); err != nil {
+			fmt.Fprintf(os.Stderr, "スナップショットの読み込みに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		if files[archiveConfigName], err = os.ReadFile(config.FilePath()); err != nil {
+			fmt.Fprintf(os.Stderr, "設定ファイルの読み込みに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		passphrase := ""
+		if encrypt {
+			if passphrase, err = readPassphrase(true); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "出力ファイルの作成に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		if err := archive.Create(f, files, data.SchemaVersion, passphrase); err != nil {
+			f.Close()
+			os.Remove(output)
+			fmt.Fprintf(os.Stderr, "書類一式の作成に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		if err := f.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "書類一式の保存に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		if encrypt {
+			fmt.Printf("暗号化した書類一式を作成しました: %s\n", output)
+		} else {
+			fmt.Printf("書類一式を作成しました: %s\n", output)
+		}
+	},
+}
+
+// archiveOpenCmd represents the archive open command
+var archiveOpenCmd = &cobra.Command{
+	Use:   "open [FILE]",
+	Short: "書類一式を検証し, 展開または復元します",
+	Long: `書類一式を読み込み, manifest のチェックサムと照合して内容を表示します
+--extract を指定すると含まれるファイルをディレクトリに展開します
+--restore を指定するとデータベースを現在のデータベースと置き換えます (設定ファイルは置き換えません)`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		extractDir, _ := cmd.Flags().GetString("extract")
+		restore, _ := cmd.Flags().GetBool("restore")
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ファイルを開けません: %v\n", err)
+			os.Exit(1)
+		}
+		bundle, err := archive.Open(f, func() (string, error) { return readPassphrase(false) })
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "書類一式を開けません: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("作成日時: %s\n", bundle.Manifest.CreatedAt)
+		fmt.Printf("スキーマ: v%d\n", bundle.Manifest.SchemaVersion)
+		fmt.Printf("暗号化: %t\n", bundle.Encrypted)
+		for _, entry := range bundle.Manifest.Files {
+			fmt.Printf("  %-12s %8d バイト  sha256:%s\n", entry.Name, entry.Size, entry.SHA256)
+		}
+		fmt.Println("manifest との照合: OK")
+
+		if extractDir != "" {
+			if err := os.MkdirAll(extractDir, 0700); err != nil {
+				fmt.Fprintf(os.Stderr, "展開先の作成に失敗しました: %v\n", err)
+				os.Exit(1)
+			}
+			names := make([]string, 0, len(bundle.Files))
+			for name := range bundle.Files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				// 展開先の外に書き出さないよう, ファイル名のみを使う
+				path := filepath.Join(extractDir, filepath.Base(name))
+				if err := os.WriteFile(path, bundle.Files[name], 0600); err != nil {
+					fmt.Fprintf(os.Stderr, "展開に失敗しました: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("展開しました: %s\n", path)
+			}
+		}
+
+		if restore {
+			db, ok := bundle.Files[archiveDBName]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "%s が含まれていません\n", archiveDBName)
+				os.Exit(1)
+			}
+			tmp, err := os.CreateTemp("", "kk-invest-restore-*.db")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "一時ファイルの作成に失敗しました: %v\n", err)
+				os.Exit(1)
+			}
+			defer os.Remove(tmp.Name())
+			if _, err := tmp.Write(db); err != nil {
+				tmp.Close()
+				fmt.Fprintf(os.Stderr, "一時ファイルの書き込みに失敗しました: %v\n", err)
+				os.Exit(1)
+			}
+			tmp.Close()
+
+			if _, err := data.VerifyBackup(tmp.Name()); err != nil {
+				fmt.Fprintf(os.Stderr, "データベースを復元できません: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print("現在のデータベースを置き換えます. 続行しますか? [y/N]: ")
+			reader := bufio.NewReader(os.Stdin)
+			confirm, _ := reader.ReadString('\n')
+			if strings.TrimSpace(strings.ToLower(confirm)) != "y" {
+				fmt.Println("操作を中止しました")
+				return
+			}
+			if err := data.RestoreBackup(tmp.Name()); err != nil {
+				fmt.Fprintf(os.Stderr, "復元に失敗しました: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("データベースを復元しました")
+		}
+	},
+}
+
+// パスフレーズを環境変数または端末から読み込む
+// confirm が true の場合は確認のため2回入力させる
+func readPassphrase(confirm bool) (string, error) {
+	if p := os.Getenv(passphraseEnv); p != "" {
+		return p, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("パスフレーズを入力できません. 端末から実行するか %s を設定してください", passphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "パスフレーズ> ")
+	p, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(p) == 0 {
+		return "", fmt.Errorf("パスフレーズが空です")
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "パスフレーズ (確認)> ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(p) {
+			return "", fmt.Errorf("パスフレーズが一致しません")
+		}
+	}
+	return string(p), nil
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// archiveCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// archiveCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	archiveCmd.AddCommand(archiveCreateCmd)
+	archiveCmd.AddCommand(archiveOpenCmd)
+
+	archiveCreateCmd.Flags().Bool("encrypt", false, "パスフレーズで暗号化する")
+	archiveCreateCmd.Flags().StringP("output", "o", "", "出力先ファイル (省略時は日時付きのファイル名)")
+
+	archiveOpenCmd.Flags().String("extract", "", "含まれるファイルを展開するディレクトリ")
+	archiveOpenCmd.Flags().Bool("restore", false, "データベースを復元する")
+}
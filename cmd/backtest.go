@@ -0,0 +1,118 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"kk-invest/internal/backtest"
+	"kk-invest/internal/data"
+	"kk-invest/internal/strategy"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// backtestCmd represents the backtest command
+var backtestCmd = &cobra.Command{
+	Use:   "backtest",
+	Short: "記録された基準価額で戦略を検証します",
+	Long: `記録されている基準価額を1日ずつ再生し, 各日の時点の状況で戦略の売却判断を行い, その売却を仮想の取引として積み上げます
+期間末の保有口数, 売却代金の合計, 実現損益, 最大下落率を表示します
+期間中に記録された実際の売却は使わず, シミュレーションの売却で置き換えます. 購入は記録どおりに使います`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, s, params, err := selectStrategy(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		transactions, prices, from, to, err := loadBacktestData(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		res, err := backtest.Run(s, transactions, prices, from, to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "バックテストに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("戦略: %s\n", name)
+		if len(params) > 0 {
+			fmt.Printf("引数: %s\n", strings.Join(params.Strings(), " "))
+		}
+		fmt.Printf("期間: %s 〜 %s\n", res.From.Format("2006-01-02"), res.To.Format("2006-01-02"))
+
+		if showTrades, _ := cmd.Flags().GetBool("trades"); showTrades {
+			fmt.Println("📈 売却履歴 --------------------")
+			for _, t := range res.Trades {
+				fmt.Printf("%s  %d口 @ %d  売却代金: %d 円  実現損益: %.0f 円\n",
+					t.Date.Format("2006-01-02"), t.Units, t.Price, t.Proceeds, t.RealizedGain)
+			}
+		}
+
+		fmt.Println("📊 バックテスト結果 --------------------")
+		fmt.Printf("売却回数: %d\n", len(res.Trades))
+		fmt.Printf("期間末の保有口数: %d 口\n", res.FinalUnits)
+		fmt.Printf("期間末の評価額: %.0f 円 (基準価額: %d)\n", res.FinalValue, res.FinalPrice)
+		fmt.Printf("売却代金の合計: %d 円\n", res.Proceeds)
+		fmt.Printf("実現損益: %.0f 円\n", res.RealizedGain)
+		if res.MaxDrawdown > 0 {
+			fmt.Printf("最大下落率: %.2f%% (%s 〜 %s)\n", res.MaxDrawdown*100,
+				res.PeakDate.Format("2006-01-02"), res.TroughDate.Format("2006-01-02"))
+		} else {
+			fmt.Println("最大下落率: 0.00%")
+		}
+	},
+}
+
+// バックテストに使う取引履歴と価格履歴, および --from, --to で指定した期間を読み込む
+// 期間を省略した場合は, 記録されている基準価額の最初と最後の日付を使う
+func loadBacktestData(cmd *cobra.Command) ([]data.Transaction, []strategy.DailyPrice, time.Time, time.Time, error) {
+	transactions, err := data.GetAllTransactions()
+	if err != nil {
+		return nil, nil, time.Time{}, time.Time{}, fmt.Errorf("取引履歴の取得に失敗しました: %w", err)
+	}
+	dailyPrices, err := data.GetAllDailyPrices()
+	if err != nil {
+		return nil, nil, time.Time{}, time.Time{}, fmt.Errorf("価格履歴の取得に失敗しました: %w", err)
+	}
+	if len(dailyPrices) == 0 {
+		return nil, nil, time.Time{}, time.Time{}, fmt.Errorf("価格履歴が存在しません. 基準価格を記録してください")
+	}
+	prices := make([]strategy.DailyPrice, len(dailyPrices))
+	for i, p := range dailyPrices {
+		prices[i] = strategy.DailyPrice{Date: p.Date, Price: p.Price}
+	}
+
+	fromStr, _ := cmd.Flags().GetString("from")
+	if fromStr == "" {
+		fromStr = prices[0].Date
+	}
+	toStr, _ := cmd.Flags().GetString("to")
+	if toStr == "" {
+		toStr = prices[len(prices)-1].Date
+	}
+	from, err := time.ParseInLocation("2006-01-02", fromStr, time.Local)
+	if err != nil {
+		return nil, nil, time.Time{}, time.Time{}, fmt.Errorf("--from の日付が不正です: %w", err)
+	}
+	to, err := time.ParseInLocation("2006-01-02", toStr, time.Local)
+	if err != nil {
+		return nil, nil, time.Time{}, time.Time{}, fmt.Errorf("--to の日付が不正です: %w", err)
+	}
+	return transactions, prices, from, to, nil
+}
+
+func init() {
+	rootCmd.AddCommand(backtestCmd)
+
+	backtestCmd.Flags().String("strategy", "", "検証する戦略 (省略時は設定ファイルの strategy)")
+	backtestCmd.Flags().StringArray("param", nil, "戦略の引数 (key=value, 複数指定可)")
+	backtestCmd.Flags().String("from", "", "期間の開始日 (YYYY-MM-DD, 省略時は最初の基準価額の日付)")
+	backtestCmd.Flags().String("to", "", "期間の終了日 (YYYY-MM-DD, 省略時は最後の基準価額の日付)")
+	backtestCmd.Flags().Bool("trades", false, "仮想の売却を1件ずつ表示する")
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"kk-invest/internal/data"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "データベースのバックアップを作成します",
+	Long: `データベースの一貫したスナップショットを日時付きのファイルとして保存します
+他のプロセスがデータベースを開いている最中でも取得できます
+保存後, --keep で指定した世代数を超える古いバックアップを削除します (操作の前に自動で作成したバックアップは対象外です)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("dir")
+		keep, _ := cmd.Flags().GetInt("keep")
+		if dir == "" {
+			dir = data.BackupDir()
+		}
+
+		path, err := data.Backup(dir, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "バックアップの作成に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("バックアップを作成しました: %s\n", path)
+
+		removed, err := data.RotateBackups(dir, keep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "古いバックアップの削除に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		for _, p := range removed {
+			fmt.Printf("古いバックアップを削除しました: %s\n", p)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// backupCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// backupCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+
+	backupCmd.Flags().String("dir", "", "保存先ディレクトリ (省略時は書類パスの backups)")
+	backupCmd.Flags().Int("keep", data.DefaultBackupKeep, "保持する世代数 (0 で削除しない)")
+}
@@ -0,0 +1,260 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"kk-invest/internal/calendar"
+	"kk-invest/internal/config"
+	"kk-invest/internal/export"
+	"kk-invest/internal/strategy"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// calendarCmd represents the calendar command
+var calendarCmd = &cobra.Command{
+	Use:   "calendar",
+	Short: "売却日や積立日の予定を扱います",
+	Long:  `売却日, クレカ積立の購入日, 基準価額の記録のリマインダをカレンダー形式で扱います`,
+}
+
+// calendarHolidaysCmd represents the calendar holidays command
+var calendarHolidaysCmd = &cobra.Command{
+	Use:   "holidays",
+	Short: "休業日の一覧を表示します",
+	Long: `指定した年の休業日 (土日を除く祝日, 年末年始) を表示します
+組み込みの祝日データに, 祝日ファイル (設定ファイルの holiday_file, 省略時は書類パスの holidays.csv) の内容を重ねたものです
+祝日ファイルは 1行に "YYYY-MM-DD,名称" の形式で記述し, 日付の先頭に ! を付けるとその日を営業日として扱います`,
+	Run: func(cmd *cobra.Command, args []string) {
+		year, _ := cmd.Flags().GetInt("year")
+		if year == 0 {
+			year = time.Now().Year()
+		}
+		day := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+		if !calendar.Covers(day) {
+			fmt.Fprintf(os.Stderr, "注意: 組み込みの祝日データは %d〜%d 年のみです\n", calendar.FirstYear, calendar.LastYear)
+		}
+
+		for _, h := range calendar.Default().Holidays(year) {
+			fmt.Printf("%s (%s) %s\n", h.Date.Format("2006-01-02"), h.Date.Format("Mon"), h.Name)
+		}
+	},
+}
+
+// calendarSellDaysCmd represents the calendar sell-days command
+var calendarSellDaysCmd = &cobra.Command{
+	Use:   "sell-days",
+	Short: "売却日かどうかと, 今後の売却日を表示します",
+	Long: `戦略の売却日の規則 (引数 schedule) に従って, 基準日が売却日かどうかと今後の売却日を表示します
+売却日ごとに, 設定ファイルの settlement (省略時は翌営業日約定, 約定日から4営業日後に受渡) で計算した約定予定日と受渡予定日も表示します`,
+	Run: func(cmd *cobra.Command, args []string) {
+		count, _ := cmd.Flags().GetInt("count")
+		day := time.Now()
+		if cmd.Flags().Changed("date") {
+			dateStr, _ := cmd.Flags().GetString("date")
+			t, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "日付が不正です: %v\n", err)
+				os.Exit(1)
+			}
+			day = t
+		}
+
+		name, st, _, err := selectStrategy(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		sched, ok := strategy.SellScheduleOf(st)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "戦略 %s は売却日の規則を持ちません\n", name)
+			os.Exit(1)
+		}
+		cal := calendar.Default()
+
+		fmt.Printf("戦略: %s\n", name)
+		fmt.Printf("売却日の規則: %s\n", sched)
+		if sched.Is(day, cal) {
+			fmt.Printf("%s は売却日です\n", day.Format("2006-01-02"))
+		} else {
+			fmt.Printf("%s は売却日ではありません\n", day.Format("2006-01-02"))
+		}
+
+		upcoming := sched.Upcoming(day, count, cal)
+		if len(upcoming) == 0 {
+			fmt.Println("今後の売却日はありません")
+			return
+		}
+		lag := config.Current().SettlementDays()
+		fmt.Println("今後の売却日:")
+		for _, d := range upcoming {
+			trade, settle := cal.Settlement(d, lag.TradeLag, lag.SettleLag)
+			fmt.Printf("  %s (%s) 約定予定日: %s, 受渡予定日: %s\n", d.Format("2006-01-02"), d.Format("Mon"), trade.Format("2006-01-02"), settle.Format("2006-01-02"))
+		}
+	},
+}
+
+// calendarExportCmd represents the calendar export command
+var calendarExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "予定を .ics ファイルに書き出します",
+	Long: `今後の売却日, クレカ積立の購入日, 基準価額の記録のリマインダを iCalendar (.ics) 形式で書き出します
+次回の売却日には, 最新の基準価額から計算した売却予定口数と金額を記載します
+購入日を省略した場合は, 最後に記録された購入の日付から推定します`,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		weeks, _ := cmd.Flags().GetInt("weeks")
+		purchaseDay, _ := cmd.Flags().GetInt("purchase-day")
+		reminderAt, _ := cmd.Flags().GetString("reminder-time")
+
+		if weeks < 1 {
+			fmt.Fprintln(os.Stderr, "--weeks は 1 以上で指定してください")
+			os.Exit(1)
+		}
+		if purchaseDay < 0 || purchaseDay > 28 {
+			fmt.Fprintln(os.Stderr, "--purchase-day は 1 から 28 の範囲で指定してください")
+			os.Exit(1)
+		}
+		reminderTime, err := time.Parse("15:04", reminderAt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "時刻が不正です: %v\n", err)
+			os.Exit(1)
+		}
+
+		now := time.Now()
+		input, err := loadAnalysisInput(now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		var events []export.Event
+
+		// 売却日
+		name, st, _, err := selectStrategy(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		sched, ok := strategy.SellScheduleOf(st)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "戦略 %s は売却日の規則を持ちません\n", name)
+			os.Exit(1)
+		}
+		var units int
+		var jpy float64
+		if t, ok := st.(strategy.Targeted); ok {
+			units, jpy = t.Target(input)
+		}
+		lag := config.Current().SettlementDays()
+		for i, day := range sched.Upcoming(today, weeks, input.Calendar) {
+			e := export.Event{
+				UID:     fmt.Sprintf("sell-%s@kk-invest", day.Format("20060102")),
+				Summary: "kk-invest 売却日",
+				Date:    day,
+				AllDay:  true,
+			}
+			// 2回目以降は前回の売却と価格変動に依存するため見込みを出さない
+			if i == 0 && units > 0 {
+				e.Summary = fmt.Sprintf("kk-invest 売却日 (%d口)", units)
+				e.Description = fmt.Sprintf("売却予定口数: %d口 (%.0f 円)\n最新の基準価額から計算した見込みです. 当日に kk-invest decide で確認してください", units, jpy)
+			} else {
+				e.Description = "kk-invest decide で売却口数を確認してください"
+			}
+			trade, settle := input.Calendar.Settlement(day, lag.TradeLag, lag.SettleLag)
+			e.Description += fmt.Sprintf("\n約定予定日: %s\n受渡予定日: %s", trade.Format("2006-01-02"), settle.Format("2006-01-02"))
+			events = append(events, e)
+		}
+
+		// クレカ積立の購入日
+		lastBuy := -1
+		for i, tx := range input.Transactions {
+			if tx.Type == "buy" {
+				lastBuy = i
+			}
+		}
+		if purchaseDay == 0 && lastBuy >= 0 {
+			if t, err := time.Parse(time.RFC3339, input.Transactions[lastBuy].Datetime); err == nil {
+				purchaseDay = min(t.Day(), 28)
+			}
+		}
+		if purchaseDay > 0 {
+			first := time.Date(today.Year(), today.Month(), purchaseDay, 0, 0, 0, 0, time.Local)
+			if first.Before(today) {
+				first = first.AddDate(0, 1, 0)
+			}
+			e := export.Event{
+				UID:         "purchase@kk-invest",
+				Summary:     "kk-invest クレカ積立 購入日",
+				Description: "購入後に kk-invest add buy で記録してください",
+				Date:        first,
+				AllDay:      true,
+				RRule:       fmt.Sprintf("FREQ=MONTHLY;BYMONTHDAY=%d", purchaseDay),
+			}
+			if lastBuy >= 0 {
+				e.Description = fmt.Sprintf("前回の購入額: %d 円\n%s", input.Transactions[lastBuy].AmountJPY, e.Description)
+			}
+			events = append(events, e)
+		}
+
+		// 基準価額の記録 (平日)
+		events = append(events, export.Event{
+			UID:         "price-entry@kk-invest",
+			Summary:     "kk-invest 基準価額の記録",
+			Description: "kk-invest price add --price で当日の基準価額を記録してください",
+			Date:        time.Date(today.Year(), today.Month(), today.Day(), reminderTime.Hour(), reminderTime.Minute(), 0, 0, time.Local),
+			Duration:    15 * time.Minute,
+			RRule:       "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+			Alarm:       true,
+		})
+
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "出力ファイルの作成に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+
+		if err := export.WriteICS(f, "kk-invest", events); err != nil {
+			fmt.Fprintf(os.Stderr, "書き出しに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("予定を書き出しました: %s (%d件)\n", output, len(events))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(calendarCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// calendarCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// calendarCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	calendarCmd.AddCommand(calendarExportCmd)
+	calendarCmd.AddCommand(calendarHolidaysCmd)
+	calendarCmd.AddCommand(calendarSellDaysCmd)
+
+	calendarExportCmd.Flags().StringP("output", "o", "kk-invest.ics", "出力先ファイル")
+	calendarExportCmd.Flags().Int("weeks", 12, "書き出す売却日の数")
+	calendarExportCmd.Flags().String("strategy", "", "売却日の規則を使う戦略 (省略時は設定ファイルの strategy)")
+	calendarExportCmd.Flags().StringArray("param", nil, "戦略の引数 (key=value, 複数指定可)")
+	calendarExportCmd.Flags().Int("purchase-day", 0, "クレカ積立の購入日 (毎月の日付, 省略時は推定)")
+	calendarExportCmd.Flags().String("reminder-time", "20:00", "基準価額の記録を通知する時刻 (HH:MM)")
+
+	calendarHolidaysCmd.Flags().Int("year", 0, "表示する年 (省略時は今年)")
+
+	calendarSellDaysCmd.Flags().Int("count", 5, "表示する売却日の数")
+	calendarSellDaysCmd.Flags().String("date", "", "基準日 (YYYY-MM-DD, 省略時は本日)")
+	calendarSellDaysCmd.Flags().String("strategy", "", "使用する戦略 (省略時は設定ファイルの strategy)")
+	calendarSellDaysCmd.Flags().StringArray("param", nil, "戦略の引数 (key=value, 複数指定可)")
+}
@@ -5,9 +5,13 @@ package cmd
 
 import (
 	"fmt"
+	"kk-invest/internal/calendar"
+	"kk-invest/internal/config"
 	"kk-invest/internal/data"
 	"kk-invest/internal/strategy"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -16,45 +20,40 @@ import (
 var decideCmd = &cobra.Command{
 	Use:   "decide",
 	Short: "現在の状況に基づいて売却判断を行います",
-	Long:  `記録されている全取引履歴と価格履歴を分析し, 設定された戦略に基づいて売却すべきかどうか, どのくらい売却すべきかを判断します`,
+	Long: `記録されている全取引履歴と価格履歴を分析し, 設定された戦略に基づいて売却すべきかどうか, どのくらい売却すべきかを判断します
+戦略は --strategy または設定ファイルの strategy で指定します (一覧は kk-invest strategy list)`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("decide called")
-		transactions, err := data.GetAllTransactions()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "取引履歴の取得に失敗しました: %v\n", err)
-			os.Exit(1)
+		asOf := time.Now()
+		if cmd.Flags().Changed("date") {
+			dateStr, _ := cmd.Flags().GetString("date")
+			t, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "日付が不正です: %v\n", err)
+				os.Exit(1)
+			}
+			asOf = t
+			fmt.Printf("基準日: %s\n", dateStr)
 		}
 
-		prices, err := data.GetAllDailyPrices()
+		input, err := loadAnalysisInput(asOf)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "価格履歴の取得に失敗しました: %v\n", err)
+			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
-		if len(prices) == 0 {
+		if len(input.HistoricalPrices) == 0 {
 			fmt.Fprintln(os.Stderr, "価格履歴が存在しません. 基準価格を記録してください")
 		}
 
-		portfolio, err := data.GetPortfolioStatus()
+		name, currentStrategy, params, err := selectStrategy(cmd)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "資産状況の取得に失敗しました: %v\n", err)
+			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
-
-		historicalPrices := make([]strategy.DailyPrice, len(prices))
-		for i, p := range prices {
-			historicalPrices[i] = strategy.DailyPrice{
-				Date:  p.Date,
-				Price: p.Price,
-			}
-		}
-
-		input := strategy.AnalysisInput{
-			Transactions:     transactions,
-			HistoricalPrices: historicalPrices,
-			Portfolio:        portfolio,
+		fmt.Printf("戦略: %s\n", name)
+		if len(params) > 0 {
+			fmt.Printf("引数: %s\n", strings.Join(params.Strings(), " "))
 		}
-
-		currentStrategy := &strategy.SimpleStrategy{}
 		decision := currentStrategy.Decide(input)
 
 		fmt.Println("💰 売却判断結果 --------------------")
@@ -64,11 +63,97 @@ var decideCmd = &cobra.Command{
 			fmt.Println("売却: いいえ")
 		}
 		fmt.Printf("売却口数: %d\n", decision.UnitsToSell)
+		if decision.ExpectedProceeds > 0 {
+			fmt.Printf("売却代金の見込み: %.0f 円\n", decision.ExpectedProceeds)
+		}
 		fmt.Printf("理由: %s\n", decision.Reason)
+		if decision.ShouldSell {
+			lag := config.Current().SettlementDays()
+			trade, settle := input.Calendar.Settlement(input.Date, lag.TradeLag, lag.SettleLag)
+			fmt.Printf("約定予定日: %s\n", trade.Format("2006-01-02"))
+			fmt.Printf("受渡予定日: %s\n", settle.Format("2006-01-02"))
+		}
+		if p, ok := currentStrategy.(strategy.Projector); ok {
+			printProjection(p.Projection(input))
+		}
+		if !calendar.Covers(input.Date) {
+			fmt.Fprintf(os.Stderr, "注意: 組み込みの祝日データは %d〜%d 年のみです. それ以外の年は祝日ファイルで補ってください\n", calendar.FirstYear, calendar.LastYear)
+		}
 
 	},
 }
 
+// 戦略の売却の見通しを, 最初の12回と最後の1回に絞って表示する
+func printProjection(rows []strategy.ProjectedSell) {
+	if len(rows) == 0 {
+		return
+	}
+	const head = 12
+	fmt.Printf("📅 売却の見通し (%d回) --------------------\n", len(rows))
+	for i, r := range rows {
+		if i == head && len(rows) > head+1 {
+			fmt.Printf("  ... (%d回省略)\n", len(rows)-head-1)
+		}
+		if i >= head && i < len(rows)-1 {
+			continue
+		}
+		fmt.Printf("  %s  %6.2f%%  売却: %10.0f 円  残高: %12.0f 円\n", r.Date.Format("2006-01-02"), r.Fraction*100, r.Amount, r.Balance)
+	}
+}
+
+// --strategy, 設定ファイル, 既定値の順に使う戦略の名前を決める
+func strategyName(cmd *cobra.Command) string {
+	if cmd.Flags().Changed("strategy") {
+		name, _ := cmd.Flags().GetString("strategy")
+		return name
+	}
+	if configured := config.Current().Strategy; configured != "" {
+		return configured
+	}
+	return strategy.DefaultName
+}
+
+// strategyName で決めた戦略を生成する
+// 引数は既定値に設定ファイルの strategy_params, --param の順に重ねる
+func selectStrategy(cmd *cobra.Command) (string, strategy.Strategy, strategy.ParamValues, error) {
+	name := strategyName(cmd)
+	paramFlags, _ := cmd.Flags().GetStringArray("param")
+	overrides, err := strategy.ParseParamFlags(paramFlags)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	s, values, err := strategy.Build(name, strategy.ParamsFromConfig(config.Current().StrategyParams[name]), overrides)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("戦略 %s の設定に失敗しました: %w", name, err)
+	}
+	return name, s, values, nil
+}
+
+// 記録されている取引履歴と価格履歴から, asOf 時点の売却判断の入力を組み立てる
+// 取引は asOf の時点の記録 (その後の削除や編集を戻したもの) を使う
+func loadAnalysisInput(asOf time.Time) (strategy.AnalysisInput, error) {
+	transactions, err := data.GetTransactionsAsOf(asOf)
+	if err != nil {
+		return strategy.AnalysisInput{}, fmt.Errorf("取引履歴の取得に失敗しました: %w", err)
+	}
+
+	prices, err := data.GetAllDailyPrices()
+	if err != nil {
+		return strategy.AnalysisInput{}, fmt.Errorf("価格履歴の取得に失敗しました: %w", err)
+	}
+
+	historicalPrices := make([]strategy.DailyPrice, len(prices))
+	for i, p := range prices {
+		historicalPrices[i] = strategy.DailyPrice{
+			Date:  p.Date,
+			Price: p.Price,
+		}
+	}
+
+	return strategy.InputAsOf(asOf, transactions, historicalPrices), nil
+}
+
 func init() {
 	rootCmd.AddCommand(decideCmd)
 
@@ -81,4 +166,8 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// decideCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+
+	decideCmd.Flags().String("strategy", "", "使用する戦略 (省略時は設定ファイルの strategy)")
+	decideCmd.Flags().StringArray("param", nil, "戦略の引数 (key=value, 複数指定可)")
+	decideCmd.Flags().String("date", "", "判断の基準日 (YYYY-MM-DD, 省略時は本日). その日の時点で記録されていた取引と価格のみを使います (削除済みの古い変更履歴の分は戻せません)")
 }
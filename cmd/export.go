@@ -0,0 +1,102 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"kk-invest/internal/config"
+	"kk-invest/internal/data"
+	"kk-invest/internal/export"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "取引履歴を他のツール向けの形式で書き出します",
+	Long: `記録されている取引履歴と基準価額を指定した形式で書き出します
+	beancount: beancount 形式 (bean-check で検証可能)
+	ledger:    ledger 形式 (hledger でも読み込み可能)
+	moneyforward: マネーフォワード ME の手入力用 CSV (Shift_JIS)
+	zaim:         Zaim の手入力用 CSV (Shift_JIS)
+beancount, ledger では購入・売却は口数を銘柄とし取得原価付きで, 基準価額は価格として, 分配金・手数料は収益・費用として出力します
+moneyforward, zaim では売却代金・分配金を収入, クレカ積立・手数料を支出として出力します
+分類は設定ファイルの household_categories で変更できます`,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		acc := export.DefaultAccounts()
+		if cmd.Flags().Changed("commodity") {
+			acc.Commodity, _ = cmd.Flags().GetString("commodity")
+		}
+
+		var write func(io.Writer, []data.Transaction, []data.DailyPrice, export.Accounts) error
+		switch format {
+		case "beancount":
+			write = export.WriteBeancount
+		case "ledger":
+			write = export.WriteLedger
+		case export.MoneyForward, export.Zaim:
+			categories := config.Current().HouseholdCategories[format]
+			write = func(w io.Writer, transactions []data.Transaction, _ []data.DailyPrice, _ export.Accounts) error {
+				return export.WriteHousehold(w, format, transactions, categories)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "対応していない形式です: %s\n", format)
+			os.Exit(1)
+		}
+
+		transactions, err := data.GetAllTransactions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "取引の取得に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		prices, err := data.GetAllDailyPrices()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "基準価額の取得に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		var w io.Writer = os.Stdout
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "出力ファイルの作成に失敗しました: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if err := write(w, transactions, prices, acc); err != nil {
+			fmt.Fprintf(os.Stderr, "書き出しに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		if output != "" {
+			fmt.Printf("書き出しました: %s\n", output)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// exportCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// exportCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+
+	exportCmd.Flags().String("format", "beancount", "出力形式 (beancount, ledger, moneyforward, zaim)")
+	exportCmd.Flags().StringP("output", "o", "", "出力先ファイル (省略時は標準出力)")
+	exportCmd.Flags().String("commodity", export.DefaultAccounts().Commodity, "口数を表す銘柄名")
+}
@@ -0,0 +1,189 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"kk-invest/internal/backtest"
+	"kk-invest/internal/config"
+	"kk-invest/internal/strategy"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// optimizeCmd represents the optimize command
+var optimizeCmd = &cobra.Command{
+	Use:   "optimize",
+	Short: "戦略の引数を探索します",
+	Long: `戦略の引数の組み合わせごとに, 記録された基準価額でバックテストを並列に実行し, 目的に従って順位を付けます
+探索範囲は --grid で引数ごとに指定します (複数指定可)
+	数値:   --grid sell_ratio=0.1:0.9:0.1  (start:stop:step)
+	列挙:   --grid "schedule=weekly:sun|bizday:1"
+引数名は _ で区切った一部 (例: ratio) でも指定できます
+目的 (--objective):
+	proceeds  売却代金の合計が大きい順
+	drawdown  最大下落率が小さい順
+	variance  1回あたりの売却代金の分散が小さい順
+売却が1回もない組み合わせは末尾に表示します`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// 引数は探索範囲で指定するため, ここでは戦略の名前だけを決める
+		name := strategyName(cmd)
+		s, err := strategy.New(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		c, ok := s.(strategy.Configurable)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "戦略 %s は引数を受け付けません\n", name)
+			os.Exit(1)
+		}
+
+		gridFlags, _ := cmd.Flags().GetStringArray("grid")
+		if len(gridFlags) == 0 {
+			fmt.Fprintln(os.Stderr, "--grid で探索範囲を指定する必要があります")
+			os.Exit(1)
+		}
+		axes, err := backtest.ParseGrid(c.Params(), gridFlags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		objectiveStr, _ := cmd.Flags().GetString("objective")
+		objective, err := backtest.ParseObjective(objectiveStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		paramFlags, _ := cmd.Flags().GetStringArray("param")
+		overrides, err := strategy.ParseParamFlags(paramFlags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		layers := []map[string]string{strategy.ParamsFromConfig(config.Current().StrategyParams[name]), overrides}
+
+		transactions, prices, from, to, err := loadBacktestData(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		results := backtest.Sweep(name, layers, axes, transactions, prices, from, to, jobs)
+		if err := backtest.Rank(results, objective); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		names := make([]string, len(axes))
+		for i, a := range axes {
+			names[i] = a.Name
+		}
+
+		fmt.Printf("戦略: %s\n", name)
+		fmt.Printf("期間: %s 〜 %s\n", from.Format("2006-01-02"), to.Format("2006-01-02"))
+		fmt.Printf("組み合わせ: %d, 目的: %s\n", len(results), objective)
+		fmt.Println("🏆 探索結果 --------------------")
+		top, _ := cmd.Flags().GetInt("top")
+		var failed []backtest.SweepResult
+		for i, r := range results {
+			if r.Err != nil {
+				failed = append(failed, r)
+				continue
+			}
+			if i >= top {
+				continue
+			}
+			fmt.Printf("%3d. %s\n", i+1, formatCombo(names, r.Params))
+			fmt.Printf("     売却: %d回, 売却代金: %d 円, 実現損益: %.0f 円, 最大下落率: %.2f%%, 売却代金の標準偏差: %.0f 円\n",
+				len(r.Result.Trades), r.Result.Proceeds, r.Result.RealizedGain, r.Result.MaxDrawdown*100, math.Sqrt(r.Variance))
+		}
+		if len(failed) > 0 {
+			fmt.Fprintf(os.Stderr, "%d 件の組み合わせは実行できませんでした (例: %s: %v)\n", len(failed), formatCombo(names, failed[0].Params), failed[0].Err)
+		}
+
+		if path, _ := cmd.Flags().GetString("csv"); path != "" {
+			if err := writeSweepCSV(path, names, results); err != nil {
+				fmt.Fprintf(os.Stderr, "CSV の書き出しに失敗しました: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("全ての結果を書き出しました: %s\n", path)
+		}
+	},
+}
+
+func formatCombo(names []string, params map[string]string) string {
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = n + "=" + params[n]
+	}
+	return strings.Join(parts, " ")
+}
+
+// 探索結果を順位順に CSV で書き出す
+func writeSweepCSV(path string, names []string, results []backtest.SweepResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := append([]string{"rank"}, names...)
+	header = append(header, "trades", "proceeds", "realized_gain", "final_units", "final_value", "max_drawdown", "proceeds_variance", "error")
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for i, r := range results {
+		row := []string{strconv.Itoa(i + 1)}
+		for _, n := range names {
+			row = append(row, r.Params[n])
+		}
+		if r.Err != nil {
+			row = append(row, "", "", "", "", "", "", "", r.Err.Error())
+		} else {
+			res := r.Result
+			row = append(row,
+				strconv.Itoa(len(res.Trades)),
+				strconv.Itoa(res.Proceeds),
+				strconv.FormatFloat(res.RealizedGain, 'f', 0, 64),
+				strconv.Itoa(res.FinalUnits),
+				strconv.FormatFloat(res.FinalValue, 'f', 0, 64),
+				strconv.FormatFloat(res.MaxDrawdown, 'f', 6, 64),
+				strconv.FormatFloat(r.Variance, 'f', 0, 64),
+				"",
+			)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(optimizeCmd)
+
+	optimizeCmd.Flags().String("strategy", "", "探索する戦略 (省略時は設定ファイルの strategy)")
+	optimizeCmd.Flags().StringArray("param", nil, "固定する戦略の引数 (key=value, 複数指定可)")
+	optimizeCmd.Flags().StringArray("grid", nil, "探索範囲 (name=start:stop:step または name=a|b|c, 複数指定可)")
+	optimizeCmd.Flags().String("objective", string(backtest.ObjectiveProceeds), "順位付けの目的 (proceeds, drawdown, variance)")
+	optimizeCmd.Flags().String("from", "", "期間の開始日 (YYYY-MM-DD, 省略時は最初の基準価額の日付)")
+	optimizeCmd.Flags().String("to", "", "期間の終了日 (YYYY-MM-DD, 省略時は最後の基準価額の日付)")
+	optimizeCmd.Flags().Int("jobs", runtime.NumCPU(), "並列に実行する数")
+	optimizeCmd.Flags().Int("top", 10, "表示する上位の件数")
+	optimizeCmd.Flags().String("csv", "", "全ての結果を書き出す CSV ファイル")
+}
@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"fmt"
+	"kk-invest/internal/calendar"
 	"kk-invest/internal/data"
 	"os"
 	"time"
@@ -27,8 +28,9 @@ var priceAddCmd = &cobra.Command{
 	Short: "新しい基準価額を追加します",
 	Long: `指定した日付の基準価額 (1万口あたり) を追加します
 		日付を省略した場合: 
-			午前9時まで: 前日
-			それ以降:    当日`,
+			午前9時まで: 前営業日
+			それ以降:    当日 (休業日の場合は前営業日)
+		営業日は土日, 祝日, 年末年始を除いた日です`,
 	Run: func(cmd *cobra.Command, args []string) {
 		price, _ := cmd.Flags().GetInt("price")
 		if price == 0 {
@@ -44,21 +46,29 @@ var priceAddCmd = &cobra.Command{
 
 		dateStr, _ := cmd.Flags().GetString("date")
 
+		cal := calendar.Default()
 		if dateStr == "" {
 			now := time.Now()
 			if now.Hour() < 9 {
-				dateStr = now.AddDate(0, 0, -1).Format("2006-01-02")
-				fmt.Printf("自動 (前日): %s\n", dateStr)
-			} else {
+				dateStr = cal.PrevBusinessDay(now).Format("2006-01-02")
+				fmt.Printf("自動 (前営業日): %s\n", dateStr)
+			} else if cal.IsBusinessDay(now) {
 				dateStr = now.Format("2006-01-02")
 				fmt.Printf("自動 (当日): %s\n", dateStr)
+			} else {
+				dateStr = cal.OnOrBefore(now).Format("2006-01-02")
+				fmt.Printf("自動 (前営業日): %s\n", dateStr)
 			}
 		} else {
 			// 日付のフォーマットチェック
-			if _, err := time.Parse("2006-01-02", dateStr); err != nil {
+			t, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+			if err != nil {
 				fmt.Fprintf(os.Stderr, "日付が不正です: %v\n", err)
 				os.Exit(1)
 			}
+			if !cal.IsBusinessDay(t) {
+				fmt.Fprintf(os.Stderr, "注意: %s は営業日ではありません\n", dateStr)
+			}
 		}
 
 		if err := data.AddDailyPrice(dateStr, price); err != nil {
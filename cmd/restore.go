@@ -0,0 +1,61 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"kk-invest/internal/data"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd represents the restore-backup command
+var restoreCmd = &cobra.Command{
+	Use:   "restore-backup [FILE]",
+	Short: "バックアップからデータベースを復元します",
+	Long: `指定したバックアップファイルの整合性とスキーマのバージョンを確認した上で, 現在のデータベースと置き換えます
+置き換え前の現在のデータベースは自動でバックアップされます`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+
+		version, err := data.VerifyBackup(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "バックアップを復元できません: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("整合性チェック: OK (スキーマ v%d)\n", version)
+
+		fmt.Printf("%s で現在のデータベースを置き換えます. 続行しますか? [y/N]: ", path)
+		reader := bufio.NewReader(os.Stdin)
+		confirm, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(confirm)) != "y" {
+			fmt.Println("操作を中止しました")
+			return
+		}
+
+		if err := data.RestoreBackup(path); err != nil {
+			fmt.Fprintf(os.Stderr, "復元に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("データベースを復元しました")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// restoreCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// restoreCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+}
@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"fmt"
+	"kk-invest/internal/calendar"
 	"kk-invest/internal/config"
 	"kk-invest/internal/data"
 	"os"
@@ -35,14 +36,27 @@ to quickly create a Cobra application.`,
 		}
 		wasInitalSetup = isInit
 
+		registerStrategyParams()
+		if err := registerComposites(); err != nil {
+			fmt.Fprintf(os.Stderr, "組み合わせた戦略の登録に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := calendar.LoadUserFile(config.HolidayFilePath()); err != nil {
+			fmt.Fprintf(os.Stderr, "祝日ファイルの読み込みに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
 		if err := data.InitDB(config.ResolvedDataPath); err != nil {
 			fmt.Fprintf(os.Stderr, "データベースの初期化に失敗しました: %v\n", err)
 			os.Exit(1)
 		}
 
-		if err := data.PurgeOldRecords(30); err != nil {
-			fmt.Fprintf(os.Stderr, "古い記録の削除に失敗しました: %v\n", err)
-			os.Exit(1)
+		if days := config.Current().PurgeAfterDays; days > 0 {
+			if err := data.PurgeOldRecords(days); err != nil {
+				fmt.Fprintf(os.Stderr, "古い記録の削除に失敗しました: %v\n", err)
+				os.Exit(1)
+			}
 		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -0,0 +1,175 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"kk-invest/internal/backtest"
+	"kk-invest/internal/config"
+	"kk-invest/internal/data"
+	"kk-invest/internal/strategy"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// simulateCmd represents the simulate command
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "保有資産が尽きる確率をモンテカルロ法で試算します",
+	Long: `現在の保有口数と最新の基準価額から, 将来の基準価額の経路を多数生成し, 経路ごとに戦略の売却判断を実行します
+期間末の評価額と売却代金の合計の分布, および評価額が --floor を下回る (枯渇する) 確率を表示します
+基準価額の経路は, 記録された基準価額の日次収益率をブロック単位で復元抽出して作ります
+--mean と --vol を指定した場合は, その期待収益率と変動率の正規分布から作ります
+--expense を指定した場合は, 毎月1日にその金額を現金残高から引き出したものとして記録します (bucket 戦略など)
+期間は最後に記録された基準価額の日付 (--start で変更可) から始まるため, 同じ記録と --seed からは常に同じ結果になります`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _, params, err := selectStrategy(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		transactions, err := data.GetAllTransactions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "取引履歴の取得に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		dailyPrices, err := data.GetAllDailyPrices()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "価格履歴の取得に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		if len(dailyPrices) == 0 {
+			fmt.Fprintln(os.Stderr, "価格履歴が存在しません. 基準価格を記録してください")
+			os.Exit(1)
+		}
+		prices := make([]strategy.DailyPrice, len(dailyPrices))
+		for i, p := range dailyPrices {
+			prices[i] = strategy.DailyPrice{Date: p.Date, Price: p.Price}
+		}
+
+		var model backtest.ReturnModel
+		if cmd.Flags().Changed("mean") || cmd.Flags().Changed("vol") {
+			mean, _ := cmd.Flags().GetFloat64("mean")
+			vol, _ := cmd.Flags().GetFloat64("vol")
+			if vol < 0 {
+				fmt.Fprintln(os.Stderr, "--vol は 0 以上で指定してください")
+				os.Exit(1)
+			}
+			model = backtest.Normal{Mean: mean, Vol: vol}
+		} else {
+			block, _ := cmd.Flags().GetInt("block")
+			returns := backtest.LogReturns(prices)
+			if block < 1 {
+				fmt.Fprintln(os.Stderr, "--block は 1 以上で指定してください")
+				os.Exit(1)
+			}
+			if len(returns) < block {
+				fmt.Fprintf(os.Stderr, "記録された基準価額が少なすぎます (%d日分, ブロック長: %d). --mean と --vol を指定してください\n", len(returns)+1, block)
+				os.Exit(1)
+			}
+			model = backtest.BlockBootstrap{Returns: returns, Block: block}
+		}
+
+		// 実行した日に左右されないよう, 既定では最後に記録された基準価額の日付から始める
+		lastDate := prices[len(prices)-1].Date
+		startStr, _ := cmd.Flags().GetString("start")
+		if startStr == "" {
+			startStr = lastDate
+		}
+		start, err := time.ParseInLocation("2006-01-02", startStr, time.Local)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--start の日付が不正です: %v\n", err)
+			os.Exit(1)
+		}
+		if startStr < lastDate {
+			fmt.Fprintf(os.Stderr, "--start は最後に記録された基準価額の日付 (%s) 以降で指定してください\n", lastDate)
+			os.Exit(1)
+		}
+
+		paramFlags, _ := cmd.Flags().GetStringArray("param")
+		overrides, _ := strategy.ParseParamFlags(paramFlags)
+		simCfg := backtest.SimConfig{
+			Start:  start,
+			Model:  model,
+			Layers: []map[string]string{strategy.ParamsFromConfig(config.Current().StrategyParams[name]), overrides},
+		}
+		simCfg.Years, _ = cmd.Flags().GetInt("years")
+		simCfg.Paths, _ = cmd.Flags().GetInt("paths")
+		simCfg.Seed, _ = cmd.Flags().GetUint64("seed")
+		simCfg.Floor, _ = cmd.Flags().GetFloat64("floor")
+		simCfg.Jobs, _ = cmd.Flags().GetInt("jobs")
+		simCfg.MonthlyExpense, _ = cmd.Flags().GetInt("expense")
+
+		res, err := backtest.Simulate(name, transactions, prices, simCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "シミュレーションに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("戦略: %s\n", name)
+		if len(params) > 0 {
+			fmt.Printf("引数: %s\n", strings.Join(params.Strings(), " "))
+		}
+		fmt.Printf("期間: %s 〜 %s (%d年)\n", res.Start.Format("2006-01-02"), res.End.Format("2006-01-02"), simCfg.Years)
+		fmt.Printf("経路: %d, 乱数の種: %d\n", simCfg.Paths, simCfg.Seed)
+		fmt.Printf("収益率: %s\n", model)
+		fmt.Printf("開始時点: %d 口 (評価額: %.0f 円)\n", res.InitialUnits, res.InitialValue)
+
+		fmt.Println("🎲 シミュレーション結果 --------------------")
+		fmt.Printf("枯渇確率 (評価額が %.0f 円未満): %.2f%%\n", simCfg.Floor, res.DepletionProbability()*100)
+		step := max(simCfg.Years/6, 1)
+		for y := step; y < simCfg.Years; y += step {
+			fmt.Printf("  %2d年後まで: %.2f%%\n", y, res.DepletionProbabilityBy(res.Start.AddDate(y, 0, 0))*100)
+		}
+		if simCfg.MonthlyExpense > 0 {
+			fmt.Printf("現金残高の不足 (毎月 %d 円の引き出し): %.2f%%\n", simCfg.MonthlyExpense, res.CashShortProbability()*100)
+		}
+
+		finalValues := make([]float64, len(res.Outcomes))
+		proceeds := make([]float64, len(res.Outcomes))
+		var depletedYears []float64
+		for i, o := range res.Outcomes {
+			finalValues[i] = o.FinalValue
+			proceeds[i] = float64(o.Proceeds)
+			if o.Depleted {
+				depletedYears = append(depletedYears, o.DepletedAt.Sub(res.Start).Hours()/24/365.25)
+			}
+		}
+		percentiles := []float64{5, 25, 50, 75, 95}
+		fmt.Println("期間末の評価額:")
+		for _, p := range percentiles {
+			fmt.Printf("  %2.0f%%: %.0f 円\n", p, backtest.Percentile(finalValues, p))
+		}
+		fmt.Println("売却代金の合計:")
+		for _, p := range percentiles {
+			fmt.Printf("  %2.0f%%: %.0f 円\n", p, backtest.Percentile(proceeds, p))
+		}
+		if len(depletedYears) > 0 {
+			fmt.Printf("枯渇までの年数 (枯渇した経路): 5%%: %.1f年, 中央値: %.1f年, 95%%: %.1f年\n",
+				backtest.Percentile(depletedYears, 5), backtest.Percentile(depletedYears, 50), backtest.Percentile(depletedYears, 95))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+
+	simulateCmd.Flags().String("strategy", "", "使用する戦略 (省略時は設定ファイルの strategy)")
+	simulateCmd.Flags().StringArray("param", nil, "戦略の引数 (key=value, 複数指定可)")
+	simulateCmd.Flags().String("start", "", "シミュレーションの開始日 (YYYY-MM-DD, 省略時は最後に記録された基準価額の日付). この翌日から基準価額を生成する")
+	simulateCmd.Flags().Int("years", 30, "シミュレーションの年数")
+	simulateCmd.Flags().Int("paths", 1000, "生成する経路の数")
+	simulateCmd.Flags().Uint64("seed", 1, "乱数の種")
+	simulateCmd.Flags().Int("block", 20, "ブロック・ブートストラップのブロック長 (営業日)")
+	simulateCmd.Flags().Float64("mean", 0, "年率の期待収益率 (例: 0.05). 指定した場合は正規分布で経路を生成する")
+	simulateCmd.Flags().Float64("vol", 0, "年率の変動率 (例: 0.18)")
+	simulateCmd.Flags().Float64("floor", 10000, "評価額がこの金額 (円) を下回った時点で枯渇とみなす")
+	simulateCmd.Flags().Int("jobs", runtime.NumCPU(), "並列に実行する数")
+	simulateCmd.Flags().Int("expense", 0, "毎月1日に現金残高から引き出す金額 (円)")
+}
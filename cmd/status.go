@@ -15,7 +15,7 @@ import (
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "現在の資産状況を表示します",
-	Long:  `現在の総投資額と総保有口数を計算して表示します`,
+	Long:  `現在の総投資額と総保有口数, 取得原価と元本, 現金残高, 最新の基準価額での評価額と評価損益を計算して表示します`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("status called")
 		status, err := data.GetPortfolioStatus()
@@ -26,6 +26,15 @@ var statusCmd = &cobra.Command{
 
 		fmt.Printf("総投資額: %d 円\n", status.TotalInvestment)
 		fmt.Printf("総保有口数: %d 口\n", status.TotalUnits)
+		fmt.Printf("取得原価: %d 円\n", status.CostBasis)
+		fmt.Printf("元本: %d 円\n", status.Principal)
+		fmt.Printf("現金残高: %d 円\n", status.Cash)
+		if status.TotalUnits > 0 && status.CurrentValue == 0 {
+			fmt.Println("評価額: - (基準価額が記録されていません)")
+			return
+		}
+		fmt.Printf("評価額: %d 円\n", status.CurrentValue)
+		fmt.Printf("評価損益: %+d 円\n", status.UnrealizedPL)
 	},
 }
 
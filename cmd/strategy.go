@@ -0,0 +1,253 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"kk-invest/internal/config"
+	"kk-invest/internal/rules"
+	"kk-invest/internal/strategy"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// strategyCmd represents the strategy command
+var strategyCmd = &cobra.Command{
+	Use:   "strategy",
+	Short: "売却判断の戦略を扱います",
+	Long:  `登録されている売却判断の戦略の一覧や詳細, 判断に使っている状態を表示します`,
+}
+
+// strategyListCmd represents the strategy list command
+var strategyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "登録されている戦略の一覧を表示します",
+	Long:  `登録されている戦略の名前と説明を表示します. * は現在の設定で使われる戦略です`,
+	Run: func(cmd *cobra.Command, args []string) {
+		current := config.Current().Strategy
+		if current == "" {
+			current = strategy.DefaultName
+		}
+
+		for _, r := range strategy.List() {
+			mark := " "
+			if r.Name == current {
+				mark = "*"
+			}
+			fmt.Printf("%s %-12s %s\n", mark, r.Name, r.Description)
+		}
+	},
+}
+
+// strategyDescribeCmd represents the strategy describe command
+var strategyDescribeCmd = &cobra.Command{
+	Use:   "describe [NAME]",
+	Short: "戦略の説明と引数を表示します",
+	Long:  `戦略の説明と, 指定できる引数の型, 既定値, 範囲, 現在の設定値を表示します`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		var reg *strategy.Registration
+		for _, r := range strategy.List() {
+			if r.Name == name {
+				reg = &r
+				break
+			}
+		}
+		if reg == nil {
+			fmt.Fprintf(os.Stderr, "戦略 %q は登録されていません\n", name)
+			os.Exit(1)
+		}
+
+		fmt.Printf("戦略: %s\n", reg.Name)
+		fmt.Printf("説明: %s\n", reg.Description)
+
+		s := reg.New()
+		if cs, ok := s.(*strategy.CompositeStrategy); ok {
+			fmt.Printf("組み合わせ: %s\n", cs.Def.Combine)
+			for i, child := range cs.Def.Children {
+				p := make(strategy.ParamValues)
+				for k, v := range child.Params {
+					p[k] = v
+				}
+				fmt.Println(strings.TrimRight(fmt.Sprintf("  [%d] %s %s", i+1, child.Name, strings.Join(p.Strings(), " ")), " "))
+			}
+		}
+
+		c, ok := s.(strategy.Configurable)
+		if !ok || len(c.Params()) == 0 {
+			fmt.Println("引数: なし")
+			return
+		}
+
+		configured := strategy.ParamsFromConfig(config.Current().StrategyParams[name])
+		if _, err := strategy.ResolveParams(c.Params(), configured); err != nil {
+			fmt.Fprintf(os.Stderr, "設定ファイルの引数が不正です: %v\n", err)
+		}
+
+		fmt.Println("引数:")
+		fmt.Println("  名前                 | 型         | 既定値     | 範囲                 | 設定値")
+		fmt.Println("  ---------------------+------------+------------+----------------------+-----------")
+		for _, spec := range c.Params() {
+			value := "-"
+			if v, ok := configured[spec.Name]; ok {
+				value = v
+			}
+			fmt.Printf("  %-20s | %-10s | %-10s | %-20s | %s\n", spec.Name, spec.Type, spec.Default, spec.RangeString(), value)
+			fmt.Printf("      %s\n", spec.Description)
+		}
+	},
+}
+
+// strategyStatusCmd represents the strategy status command
+var strategyStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "戦略が判断に使っている状態を表示します",
+	Long: `現在の取引履歴と価格履歴から, 戦略が判断に使っている状態を表示します
+例えば ladder 戦略では, 段ごとの到達状況と残りの段を表示します`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, s, params, err := selectStrategy(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		input, err := loadAnalysisInput(time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("戦略: %s\n", name)
+		if len(params) > 0 {
+			fmt.Printf("引数: %s\n", strings.Join(params.Strings(), " "))
+		}
+		r, ok := s.(strategy.Reporter)
+		if !ok {
+			fmt.Println("この戦略には表示できる状態がありません")
+			return
+		}
+		fmt.Print(r.Status(input))
+	},
+}
+
+// strategyCheckCmd represents the strategy check command
+var strategyCheckCmd = &cobra.Command{
+	Use:   "check FILE",
+	Short: "script 戦略の規則のファイルを確かめます",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+		program, err := rules.ParseFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "規則に誤りがあります: %v\n", err)
+			os.Exit(1)
+		}
+		stmts, actions := program.Count()
+		fmt.Printf("%s: 問題ありません (%d文, うち動作 %d)\n", path, stmts, actions)
+
+		paramFlags, _ := cmd.Flags().GetStringArray("param")
+		overrides, err := strategy.ParseParamFlags(paramFlags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		overrides["file"] = path
+		s, _, err := strategy.Build("script", strategy.ParamsFromConfig(config.Current().StrategyParams["script"]), overrides)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "戦略 script の設定に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		// 記録されている取引履歴と価格履歴で一度評価してみる
+		input, err := loadAnalysisInput(time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println()
+		fmt.Print(s.(strategy.Reporter).Status(input))
+	},
+}
+
+// strategy check のヘルプ (規則の書き方と, 使える変数・関数・動作の一覧)
+func checkHelp() string {
+	var b strings.Builder
+	b.WriteString(`script 戦略の規則のファイルを読み込み, 文法や変数の名前, 型に誤りがないかを確かめます
+問題がなければ, 記録されている取引履歴と価格履歴で規則を評価した結果を表示します
+
+規則は1行に1つの文を書き, 上から順に評価します. 最初に実行された動作で評価を終えます
+
+  # コメント
+  let ma = sma(200)
+  if history >= 200 and price < ma then hold("移動平均を下回っている")
+  if gain > 0 and month == 12 then sell_jpy(gain / 2, "年末の利益確定")
+  sell_pct(4 / sell_days_per_year)
+
+変数:
+`)
+	for _, v := range rules.Variables {
+		fmt.Fprintf(&b, "  %-20s %s (%s)\n", v.Name, v.Description, v.Type)
+	}
+	b.WriteString("\n関数:\n")
+	for _, f := range rules.Functions {
+		fmt.Fprintf(&b, "  %-20s %s\n", fmt.Sprintf("%s(%s)", f.Name, strings.Join(f.Params, ", ")), f.Description)
+	}
+	b.WriteString("\n動作 (最後の引数に説明の文字列を指定できます):\n")
+	for _, a := range rules.Actions {
+		fmt.Fprintf(&b, "  %-20s %s\n", a.Name, a.Description)
+	}
+	return b.String()
+}
+
+// 設定ファイルの composites を戦略として登録する
+// 設定ファイルの strategy_params を, 他の戦略を包む戦略から使えるように登録する
+func registerStrategyParams() {
+	params := make(map[string]map[string]string)
+	for name, p := range config.Current().StrategyParams {
+		params[name] = strategy.ParamsFromConfig(p)
+	}
+	strategy.SetConfigParams(params)
+}
+
+func registerComposites() error {
+	defs := make(map[string]strategy.CompositeDef)
+	for name, c := range config.Current().Composites {
+		def := strategy.CompositeDef{Description: c.Description, Combine: c.Combine}
+		for _, child := range c.Children {
+			def.Children = append(def.Children, strategy.CompositeChild{
+				Name:   child.Strategy,
+				Params: strategy.ParamsFromConfig(child.Params),
+			})
+		}
+		defs[name] = def
+	}
+	return strategy.RegisterComposites(defs)
+}
+
+func init() {
+	rootCmd.AddCommand(strategyCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// strategyCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// strategyCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	strategyCmd.AddCommand(strategyListCmd)
+	strategyCmd.AddCommand(strategyDescribeCmd)
+	strategyCmd.AddCommand(strategyStatusCmd)
+	strategyCmd.AddCommand(strategyCheckCmd)
+
+	strategyStatusCmd.Flags().String("strategy", "", "使用する戦略 (省略時は設定ファイルの strategy)")
+	strategyStatusCmd.Flags().StringArray("param", nil, "戦略の引数 (key=value, 複数指定可)")
+
+	strategyCheckCmd.Long = checkHelp()
+	strategyCheckCmd.Flags().StringArray("param", nil, "script 戦略の引数 (key=value, 複数指定可. file は FILE になります)")
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"kk-invest/internal/data"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "他の端末のデータベースと同期します",
+	Long:  `複数の端末で記録したデータベースを, 取引の識別子を使って1つに統合します`,
+}
+
+// syncMergeCmd represents the sync merge command
+var syncMergeCmd = &cobra.Command{
+	Use:   "merge [OTHER.db]",
+	Short: "他の端末のデータベースを統合します",
+	Long: `指定したデータベースの取引と基準価額のうち, 手元にないものを追加します
+両方にある取引は現在の値を照合し, 相手の論理削除と, 相手だけで編集された項目を手元のデータベースに反映します
+相手の端末で完全に削除された取引は, 手元でも論理削除します
+両方の端末で同じ取引の同じ項目が異なる値に編集されていた場合や, 変更履歴が残っておらずどちらの編集か判断できない場合,
+同じ日付の基準価額が異なる場合は, どちらを採用するか確認します
+統合の前に, 手元のデータベースは自動でバックアップされます`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prefer, _ := cmd.Flags().GetString("prefer")
+		if prefer != "" && prefer != "local" && prefer != "other" {
+			fmt.Fprintln(os.Stderr, "--prefer は local または other で指定してください")
+			os.Exit(1)
+		}
+
+		reader := bufio.NewReader(os.Stdin)
+		resolve := func(c data.MergeConflict) bool {
+			if c.Date != "" {
+				fmt.Printf("競合: 基準価額 %s\n", c.Date)
+			} else {
+				fmt.Printf("競合: 取引ID %d (%s) の %s\n", c.TransactionID, c.TransactionUID, c.Field)
+			}
+			fmt.Printf("  手元: %s\n  相手: %s\n", c.LocalValue, c.OtherValue)
+			switch prefer {
+			case "local":
+				fmt.Println("  -> 手元の値を採用します")
+				return false
+			case "other":
+				fmt.Println("  -> 相手の値を採用します")
+				return true
+			}
+			for {
+				fmt.Print("どちらを採用しますか? [l: 手元 / o: 相手]: ")
+				answer, err := reader.ReadString('\n')
+				switch strings.TrimSpace(strings.ToLower(answer)) {
+				case "l":
+					return false
+				case "o":
+					return true
+				}
+				if err != nil {
+					// 入力がない場合は手元の値を残す
+					fmt.Println("\n  -> 手元の値を採用します")
+					return false
+				}
+			}
+		}
+
+		result, err := data.MergeDatabase(args[0], resolve)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "同期に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("同期が完了しました")
+		fmt.Printf("追加した取引: %d件\n", result.AddedTransactions)
+		fmt.Printf("反映した編集: %d件\n", result.AppliedEdits)
+		fmt.Printf("反映した削除: %d件\n", result.AppliedDeletes)
+		fmt.Printf("追加した基準価額: %d件\n", result.AddedPrices)
+		fmt.Printf("競合: %d件 (相手の値を採用: %d件)\n", result.Conflicts, result.ResolvedToOther)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// syncCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// syncCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	syncCmd.AddCommand(syncMergeCmd)
+
+	syncMergeCmd.Flags().String("prefer", "", "競合時に確認せず採用する側 (local, other)")
+}
@@ -5,9 +5,12 @@ go 1.25.1
 require (
 	github.com/mattn/go-sqlite3 v1.14.32
 	github.com/spf13/cobra v1.10.1
+	golang.org/x/term v0.37.0
+	golang.org/x/text v0.40.0
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.10 // indirect
+	golang.org/x/sys v0.38.0 // indirect
 )
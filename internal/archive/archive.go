@@ -0,0 +1,187 @@
+// internal/archive/archive.go
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const manifestName = "manifest.json"
+
+// 書類一式に含まれるファイルの一覧とチェックサム
+type Manifest struct {
+	CreatedAt     string      `json:"created_at"`
+	SchemaVersion int         `json:"schema_version"`
+	Files         []FileEntry `json:"files"`
+}
+
+type FileEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// 検証済みの書類一式
+type Bundle struct {
+	Manifest  Manifest
+	Encrypted bool
+	Files     map[string][]byte
+}
+
+// files (書類一式の中での名前 -> 内容) を manifest と共に1つのファイルにまとめる
+// passphrase が空でない場合は暗号化する
+func Create(w io.Writer, files map[string][]byte, schemaVersion int, passphrase string) error {
+	manifest := Manifest{
+		CreatedAt:     time.Now().Format(time.RFC3339),
+		SchemaVersion: schemaVersion,
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if name == manifestName {
+			return fmt.Errorf("%s は予約されたファイル名です", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		manifest.Files = append(manifest.Files, FileEntry{
+			Name:   name,
+			Size:   int64(len(files[name])),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	writeEntry := func(name string, content []byte) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(content)),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+	if err := writeEntry(manifestName, manifestJSON); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := writeEntry(name, files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	if passphrase == "" {
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+	sealed, err := encrypt(buf.Bytes(), passphrase)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(sealed)
+	return err
+}
+
+// 書類一式を読み込み, manifest と照合する
+// 暗号化されている場合は passphrase を呼び出してパスフレーズを取得する
+func Open(r io.Reader, passphrase func() (string, error)) (*Bundle, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{Files: make(map[string][]byte)}
+	if IsEncrypted(raw) {
+		bundle.Encrypted = true
+		pass, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = decrypt(raw, pass); err != nil {
+			return nil, err
+		}
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("書類一式の形式が不正です: %w", err)
+	}
+	tr := tar.NewReader(gr)
+	var manifestJSON []byte
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("書類一式の読み込みに失敗しました: %w", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("書類一式の読み込みに失敗しました: %w", err)
+		}
+		if hdr.Name == manifestName {
+			manifestJSON = content
+			continue
+		}
+		bundle.Files[hdr.Name] = content
+	}
+
+	if manifestJSON == nil {
+		return nil, fmt.Errorf("%s が含まれていません", manifestName)
+	}
+	if err := json.Unmarshal(manifestJSON, &bundle.Manifest); err != nil {
+		return nil, fmt.Errorf("%s の読み込みに失敗しました: %w", manifestName, err)
+	}
+	if err := verify(bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// manifest に記載されたファイルが全て揃っていて, 内容が一致することを確認
+func verify(b *Bundle) error {
+	listed := make(map[string]bool)
+	for _, f := range b.Manifest.Files {
+		listed[f.Name] = true
+		content, ok := b.Files[f.Name]
+		if !ok {
+			return fmt.Errorf("%s が含まれていません", f.Name)
+		}
+		sum := sha256.Sum256(content)
+		if int64(len(content)) != f.Size || hex.EncodeToString(sum[:]) != f.SHA256 {
+			return fmt.Errorf("%s のチェックサムが一致しません", f.Name)
+		}
+	}
+	for name := range b.Files {
+		if !listed[name] {
+			return fmt.Errorf("manifest に記載のないファイルが含まれています: %s", name)
+		}
+	}
+	return nil
+}
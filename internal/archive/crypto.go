@@ -0,0 +1,96 @@
+// internal/archive/crypto.go
+package archive
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 暗号化された書類一式の先頭
+// magic (8) | 反復回数 (4) | salt (16) | nonce (12) | 暗号文 (AES-256-GCM)
+var magic = []byte("KKARC\x00\x00\x01")
+
+const (
+	kdfIterations = 600000
+	maxIterations = 10 * kdfIterations
+	saltSize      = 16
+	nonceSize     = 12
+	headerSize    = 8 + 4 + saltSize + nonceSize
+)
+
+var ErrDecrypt = errors.New("復号に失敗しました. パスフレーズが違うか, ファイルが破損しています")
+
+func IsEncrypted(raw []byte) bool {
+	return bytes.HasPrefix(raw, magic)
+}
+
+func deriveKey(passphrase string, salt []byte, iterations int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+}
+
+// ヘッダ全体を追加認証データとし, 改ざんを検出できるようにする
+func encrypt(plain []byte, passphrase string) ([]byte, error) {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint32(header[8:12], kdfIterations)
+	salt := header[12 : 12+saltSize]
+	nonce := header[12+saltSize:]
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	key, err := deriveKey(passphrase, salt, kdfIterations)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(header, nonce, plain, header), nil
+}
+
+func decrypt(sealed []byte, passphrase string) ([]byte, error) {
+	if len(sealed) < headerSize {
+		return nil, ErrDecrypt
+	}
+	header := sealed[:headerSize]
+	iterations := int(binary.BigEndian.Uint32(header[8:12]))
+	if iterations < 1 || iterations > maxIterations {
+		return nil, fmt.Errorf("反復回数が不正です: %d", iterations)
+	}
+	salt := header[12 : 12+saltSize]
+	nonce := header[12+saltSize:]
+
+	key, err := deriveKey(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, nonce, sealed[headerSize:], header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
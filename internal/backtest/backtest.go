@@ -0,0 +1,160 @@
+// internal/backtest/backtest.go
+package backtest
+
+import (
+	"fmt"
+	"kk-invest/internal/data"
+	"kk-invest/internal/strategy"
+	"time"
+)
+
+// 仮想の売却
+type Trade struct {
+	Date         time.Time
+	Units        int
+	Price        int     // 売却に使った基準価額 (1万口あたり)
+	Proceeds     int     // 売却代金
+	RealizedGain float64 // 移動平均法による取得原価との差額
+	Reason       string
+}
+
+// ある日の評価額 (保有口数の評価額と, 期間中の売却代金の合計)
+type Point struct {
+	Date  time.Time
+	Value float64
+}
+
+// バックテストの結果
+type Result struct {
+	From, To     time.Time
+	Trades       []Trade
+	Equity       []Point // 日ごとの評価額
+	FinalUnits   int
+	FinalPrice   int
+	FinalValue   float64 // 期間末の保有口数の評価額
+	Proceeds     int     // 期間中の売却代金の合計
+	RealizedGain float64 // 期間中の実現損益の合計
+	MaxDrawdown  float64 // 最大下落率 (0〜1)
+	PeakDate     time.Time
+	TroughDate   time.Time
+}
+
+// from から to までの各日に s.Decide を呼び出し, 売却判断を仮想の取引として積み上げる
+//
+// 取引履歴のうち from より前の売却と全ての購入はそのまま使い, from 以降に記録された売却は
+// シミュレーションの売却で置き換える. 売却は判断した日の時点で最新の基準価額で約定したものとする
+// 評価額は保有口数の評価額に期間中の売却代金を加えたもので, 最大下落率はその推移から計算する
+func Run(s strategy.Strategy, transactions []data.Transaction, prices []strategy.DailyPrice, from, to time.Time) (*Result, error) {
+	from = truncate(from)
+	to = truncate(to)
+	if to.Before(from) {
+		return nil, fmt.Errorf("期間の終了日 (%s) が開始日 (%s) より前です", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+
+	type datedTx struct {
+		day string
+		tx  data.Transaction
+	}
+	var base []datedTx
+	for _, tx := range transactions {
+		t, err := time.Parse(time.RFC3339, tx.Datetime)
+		if err != nil {
+			return nil, fmt.Errorf("取引 (ID: %d) の日時が不正です: %w", tx.ID, err)
+		}
+		day := t.In(from.Location()).Format("2006-01-02")
+		if tx.Type == "sell" && day >= from.Format("2006-01-02") {
+			continue
+		}
+		base = append(base, datedTx{day, tx})
+	}
+
+	res := &Result{From: from, To: to}
+	var ledger []data.Transaction
+	var units int
+	var cost float64
+	next := 0
+	var peak float64
+	var peakDate time.Time
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		dayStr := day.Format("2006-01-02")
+		for ; next < len(base) && base[next].day <= dayStr; next++ {
+			tx := base[next].tx
+			switch tx.Type {
+			case "buy":
+				units += tx.Units
+				cost += float64(tx.AmountJPY)
+			case "sell":
+				cost -= averageCost(cost, units, tx.Units)
+				units -= tx.Units
+			}
+			ledger = append(ledger, tx)
+		}
+
+		input := strategy.InputAsOf(day, ledger, prices)
+		if len(input.HistoricalPrices) == 0 {
+			continue
+		}
+		price := input.HistoricalPrices[len(input.HistoricalPrices)-1].Price
+
+		decision := s.Decide(input)
+		if decision.ShouldSell && decision.UnitsToSell > 0 && units > 0 {
+			sold := min(decision.UnitsToSell, units)
+			proceeds := sold * price / 10000
+			soldCost := averageCost(cost, units, sold)
+			cost -= soldCost
+			units -= sold
+
+			res.Trades = append(res.Trades, Trade{
+				Date:         day,
+				Units:        sold,
+				Price:        price,
+				Proceeds:     proceeds,
+				RealizedGain: float64(proceeds) - soldCost,
+				Reason:       decision.Reason,
+			})
+			res.Proceeds += proceeds
+			res.RealizedGain += float64(proceeds) - soldCost
+			ledger = append(ledger, data.Transaction{
+				Datetime:  day.Format(time.RFC3339),
+				Type:      "sell",
+				AmountJPY: proceeds,
+				Units:     sold,
+			})
+		}
+
+		value := float64(units)*float64(price)/10000 + float64(res.Proceeds)
+		res.Equity = append(res.Equity, Point{Date: day, Value: value})
+		if value > peak {
+			peak = value
+			peakDate = day
+		} else if peak > 0 && (peak-value)/peak > res.MaxDrawdown {
+			res.MaxDrawdown = (peak - value) / peak
+			res.PeakDate = peakDate
+			res.TroughDate = day
+		}
+		res.FinalPrice = price
+	}
+
+	if len(res.Equity) == 0 {
+		return nil, fmt.Errorf("期間中 (%s〜%s) の基準価額がありません", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+	res.FinalUnits = units
+	res.FinalValue = float64(units) * float64(res.FinalPrice) / 10000
+	return res, nil
+}
+
+// 保有口数 units, 取得原価 cost のうち sold 口分の取得原価 (移動平均法)
+func averageCost(cost float64, units, sold int) float64 {
+	if units <= 0 {
+		return 0
+	}
+	if sold >= units {
+		return cost
+	}
+	return cost * float64(sold) / float64(units)
+}
+
+func truncate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
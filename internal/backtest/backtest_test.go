@@ -0,0 +1,117 @@
+// internal/backtest/backtest_test.go
+package backtest
+
+import (
+	"kk-invest/internal/data"
+	"kk-invest/internal/strategy"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 基準価額が threshold を上回る日に units 口を売却する戦略
+type thresholdStrategy struct {
+	threshold int
+	units     int
+}
+
+func (s thresholdStrategy) Decide(input strategy.AnalysisInput) strategy.SellDecision {
+	price := input.HistoricalPrices[len(input.HistoricalPrices)-1].Price
+	if price <= s.threshold {
+		return strategy.SellDecision{Reason: "保有"}
+	}
+	return strategy.SellDecision{ShouldSell: true, UnitsToSell: s.units, Reason: "上昇"}
+}
+
+func day(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestRun(t *testing.T) {
+	transactions := []data.Transaction{
+		{ID: 1, Datetime: "2024-01-01T12:00:00+09:00", Type: "buy", AmountJPY: 10000, Units: 10000},
+		// 期間中に記録された売却はシミュレーションの売却で置き換える
+		{ID: 2, Datetime: "2024-01-03T12:00:00+09:00", Type: "sell", AmountJPY: 4500, Units: 5000},
+	}
+	prices := []strategy.DailyPrice{
+		{Date: "2024-01-01", Price: 10000},
+		{Date: "2024-01-02", Price: 12000},
+		{Date: "2024-01-03", Price: 9000},
+		{Date: "2024-01-04", Price: 15000},
+	}
+	res, err := Run(thresholdStrategy{threshold: 10000, units: 1000}, transactions, prices, day("2024-01-01"), day("2024-01-04"))
+	if err != nil {
+		t.Fatalf("予期しないエラー: %v", err)
+	}
+
+	wantTrades := []Trade{
+		{Date: day("2024-01-02"), Units: 1000, Price: 12000, Proceeds: 1200, RealizedGain: 200, Reason: "上昇"},
+		{Date: day("2024-01-04"), Units: 1000, Price: 15000, Proceeds: 1500, RealizedGain: 500, Reason: "上昇"},
+	}
+	if len(res.Trades) != len(wantTrades) {
+		t.Fatalf("売却の数: got %d, want %d (%+v)", len(res.Trades), len(wantTrades), res.Trades)
+	}
+	for i, want := range wantTrades {
+		got := res.Trades[i]
+		if !got.Date.Equal(want.Date) || got.Units != want.Units || got.Price != want.Price ||
+			got.Proceeds != want.Proceeds || math.Abs(got.RealizedGain-want.RealizedGain) > 1e-9 || got.Reason != want.Reason {
+			t.Errorf("売却 %d: got %+v, want %+v", i, got, want)
+		}
+	}
+
+	wantEquity := []float64{10000, 12000, 9300, 14700}
+	if len(res.Equity) != len(wantEquity) {
+		t.Fatalf("評価額の数: got %d, want %d", len(res.Equity), len(wantEquity))
+	}
+	for i, want := range wantEquity {
+		if math.Abs(res.Equity[i].Value-want) > 1e-9 {
+			t.Errorf("評価額 %d: got %g, want %g", i, res.Equity[i].Value, want)
+		}
+	}
+
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"期間末の保有口数", float64(res.FinalUnits), 8000},
+		{"期間末の基準価額", float64(res.FinalPrice), 15000},
+		{"期間末の評価額", res.FinalValue, 12000},
+		{"売却代金の合計", float64(res.Proceeds), 2700},
+		{"実現損益の合計", res.RealizedGain, 700},
+		{"最大下落率", res.MaxDrawdown, 0.225},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s: got %g, want %g", c.name, c.got, c.want)
+		}
+	}
+	if !res.PeakDate.Equal(day("2024-01-02")) || !res.TroughDate.Equal(day("2024-01-03")) {
+		t.Errorf("最大下落の期間: got %s〜%s, want 2024-01-02〜2024-01-03",
+			res.PeakDate.Format("2006-01-02"), res.TroughDate.Format("2006-01-02"))
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	prices := []strategy.DailyPrice{{Date: "2024-01-01", Price: 10000}}
+	tests := []struct {
+		name     string
+		from, to string
+		err      string
+	}{
+		{"終了日が開始日より前", "2024-01-02", "2024-01-01", "開始日 (2024-01-02) より前"},
+		{"期間中の基準価額がない", "2023-12-01", "2023-12-31", "基準価額がありません"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Run(thresholdStrategy{}, nil, prices, day(tt.from), day(tt.to))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("エラー %q を期待しましたが, %v でした", tt.err, err)
+			}
+		})
+	}
+}
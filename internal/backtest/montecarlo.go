@@ -0,0 +1,289 @@
+// internal/backtest/montecarlo.go
+package backtest
+
+import (
+	"fmt"
+	"kk-invest/internal/calendar"
+	"kk-invest/internal/data"
+	"kk-invest/internal/strategy"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 1年あたりの営業日数 (年率の収益率を日次に換算する際に使う)
+const BusinessDaysPerYear = 245
+
+// 日次の対数収益率の列を生成する
+type ReturnModel interface {
+	Generate(rng *rand.Rand, n int) []float64
+	String() string
+}
+
+// 記録された日次収益率から, 連続する block 日分をまとめて復元抽出する (ブロック・ブートストラップ)
+// 収益率の自己相関や変動の偏りをある程度保つことができる
+type BlockBootstrap struct {
+	Returns []float64
+	Block   int
+}
+
+// 価格履歴から日次の対数収益率を計算する
+func LogReturns(prices []strategy.DailyPrice) []float64 {
+	var returns []float64
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1].Price > 0 && prices[i].Price > 0 {
+			returns = append(returns, math.Log(float64(prices[i].Price)/float64(prices[i-1].Price)))
+		}
+	}
+	return returns
+}
+
+func (b BlockBootstrap) Generate(rng *rand.Rand, n int) []float64 {
+	out := make([]float64, 0, n)
+	for len(out) < n {
+		start := rng.IntN(len(b.Returns) - b.Block + 1)
+		out = append(out, b.Returns[start:start+b.Block]...)
+	}
+	return out[:n]
+}
+
+func (b BlockBootstrap) String() string {
+	return fmt.Sprintf("記録された基準価額のブロック・ブートストラップ (ブロック長: %d営業日, 標本: %d日)", b.Block, len(b.Returns))
+}
+
+// 年率の期待収益率 Mean と変動率 Vol の幾何ブラウン運動
+type Normal struct {
+	Mean float64
+	Vol  float64
+}
+
+func (m Normal) Generate(rng *rand.Rand, n int) []float64 {
+	mu := (m.Mean - m.Vol*m.Vol/2) / BusinessDaysPerYear
+	sigma := m.Vol / math.Sqrt(BusinessDaysPerYear)
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = mu + sigma*rng.NormFloat64()
+	}
+	return out
+}
+
+func (m Normal) String() string {
+	return fmt.Sprintf("正規分布 (年率の期待収益率: %.2f%%, 変動率: %.2f%%)", m.Mean*100, m.Vol*100)
+}
+
+// シミュレーションの条件
+type SimConfig struct {
+	Start  time.Time // 開始日 (この翌日から価格を生成する)
+	Years  int
+	Paths  int
+	Seed   uint64
+	Model  ReturnModel
+	Floor  float64 // 評価額がこの金額を下回った時点で枯渇とみなす
+	Jobs   int
+	Layers []map[string]string // 戦略の引数
+
+	// 毎月1日に現金残高から引き出す金額 (円). 現金残高を使う戦略 (bucket など) のために引き出しの取引を記録する
+	MonthlyExpense int
+}
+
+// 1つの経路の結果
+type PathOutcome struct {
+	FinalValue float64 // 期間末の保有口数の評価額
+	Proceeds   int     // 売却代金の合計
+	Trades     int
+	Depleted   bool
+	DepletedAt time.Time
+	CashShort  bool // 引き出しの時点で現金残高が不足したことがあるか
+}
+
+// シミュレーションの結果
+type SimResult struct {
+	Start, End   time.Time
+	InitialUnits int
+	InitialValue float64
+	Outcomes     []PathOutcome // 経路の番号順
+}
+
+// 記録された取引と価格の状態から, cfg.Model で生成した価格の経路ごとに戦略を実行する
+//
+// 経路 i の乱数は (Seed, i) から作るため, 並列数によらず同じ種からは同じ結果になる
+// 売却日の規則を持つ戦略 (strategy.Scheduled) は売却日だけ, それ以外の戦略は毎日 Decide を呼び出す
+// 売却はその日の時点で最新の基準価額で約定したものとする
+func Simulate(name string, transactions []data.Transaction, prices []strategy.DailyPrice, cfg SimConfig) (*SimResult, error) {
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("価格履歴が存在しません")
+	}
+	if cfg.Years < 1 || cfg.Paths < 1 {
+		return nil, fmt.Errorf("年数と経路数は 1 以上で指定してください")
+	}
+	probe, _, err := strategy.Build(name, cfg.Layers...)
+	if err != nil {
+		return nil, err
+	}
+
+	// 日付と売却日は全ての経路で共通なので, 先に計算しておく
+	cal := calendar.Default()
+	start := truncate(cfg.Start)
+	end := start.AddDate(cfg.Years, 0, 0)
+	type simDay struct {
+		date     time.Time
+		dateStr  string
+		business bool
+		decide   bool
+	}
+	var days []simDay
+	businessDays := 0
+	sched, isScheduled := strategy.SellScheduleOf(probe)
+	for d := start.AddDate(0, 0, 1); !d.After(end); d = d.AddDate(0, 0, 1) {
+		day := simDay{date: d, dateStr: d.Format("2006-01-02"), business: cal.IsBusinessDay(d), decide: true}
+		if isScheduled {
+			day.decide = sched.Is(d, cal)
+		}
+		if day.business {
+			businessDays++
+		}
+		days = append(days, day)
+	}
+
+	initial := data.ComputePortfolioStatus(transactions)
+	lastPrice := prices[len(prices)-1].Price
+	res := &SimResult{
+		Start:        start,
+		End:          end,
+		InitialUnits: initial.TotalUnits,
+		InitialValue: float64(initial.TotalUnits) * float64(lastPrice) / 10000,
+		Outcomes:     make([]PathOutcome, cfg.Paths),
+	}
+
+	runPath := func(s strategy.Strategy, i int) PathOutcome {
+		rng := rand.New(rand.NewPCG(cfg.Seed, uint64(i)))
+		returns := cfg.Model.Generate(rng, businessDays)
+
+		ledger := append(make([]data.Transaction, 0, len(transactions)+64), transactions...)
+		history := append(make([]strategy.DailyPrice, 0, len(prices)+businessDays), prices...)
+		status := *initial
+		price := float64(lastPrice)
+		var out PathOutcome
+		next := 0
+
+		for _, day := range days {
+			if day.business {
+				price *= math.Exp(returns[next])
+				next++
+				history = append(history, strategy.DailyPrice{Date: day.dateStr, Price: int(math.Round(price))})
+			}
+			current := history[len(history)-1].Price
+
+			if cfg.MonthlyExpense > 0 && day.date.Day() == 1 {
+				ledger = append(ledger, data.Transaction{
+					Datetime:  day.date.Format(time.RFC3339),
+					Type:      "withdrawal",
+					AmountJPY: cfg.MonthlyExpense,
+				})
+				if status.Cash < cfg.MonthlyExpense {
+					out.CashShort = true
+				}
+				status.Cash -= cfg.MonthlyExpense
+			}
+
+			if day.decide && status.TotalUnits > 0 {
+				portfolio := status
+				portfolio.Valuate(current)
+				decision := s.Decide(strategy.AnalysisInput{
+					Date:             day.date,
+					Transactions:     ledger,
+					HistoricalPrices: history,
+					Portfolio:        &portfolio,
+					Calendar:         cal,
+				})
+				if decision.ShouldSell && decision.UnitsToSell > 0 {
+					sold := min(decision.UnitsToSell, status.TotalUnits)
+					proceeds := sold * current / 10000
+					ledger = append(ledger, data.Transaction{
+						Datetime:  day.date.Format(time.RFC3339),
+						Type:      "sell",
+						AmountJPY: proceeds,
+						Units:     sold,
+					})
+					status.ApplySell(proceeds, sold)
+					out.Proceeds += proceeds
+					out.Trades++
+				}
+			}
+
+			value := float64(status.TotalUnits) * float64(current) / 10000
+			if !out.Depleted && value < cfg.Floor {
+				out.Depleted = true
+				out.DepletedAt = day.date
+			}
+			out.FinalValue = value
+		}
+		return out
+	}
+
+	// 戦略は状態を持たないが, 念のため並列に実行する単位ごとに生成する
+	workers := make([]strategy.Strategy, max(cfg.Jobs, 1))
+	for w := range workers {
+		if workers[w], _, err = strategy.Build(name, cfg.Layers...); err != nil {
+			return nil, err
+		}
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for _, s := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				res.Outcomes[i] = runPath(s, i)
+			}
+		}()
+	}
+	for i := 0; i < cfg.Paths; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return res, nil
+}
+
+// 引き出しの時点で現金残高が不足したことがある経路の割合
+func (r *SimResult) CashShortProbability() float64 {
+	n := 0
+	for _, o := range r.Outcomes {
+		if o.CashShort {
+			n++
+		}
+	}
+	return float64(n) / float64(len(r.Outcomes))
+}
+
+// 枯渇した経路の割合
+func (r *SimResult) DepletionProbability() float64 {
+	return r.DepletionProbabilityBy(r.End)
+}
+
+// t までに枯渇した経路の割合
+func (r *SimResult) DepletionProbabilityBy(t time.Time) float64 {
+	n := 0
+	for _, o := range r.Outcomes {
+		if o.Depleted && !o.DepletedAt.After(t) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(r.Outcomes))
+}
+
+// values の p パーセンタイル (最近順位法)
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank-1, 0), len(sorted)-1)]
+}
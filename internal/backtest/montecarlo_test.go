@@ -0,0 +1,92 @@
+// internal/backtest/montecarlo_test.go
+package backtest
+
+import (
+	"kk-invest/internal/data"
+	"kk-invest/internal/strategy"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func simInput() ([]data.Transaction, []strategy.DailyPrice) {
+	transactions := []data.Transaction{
+		{ID: 1, Datetime: "2024-01-04T12:00:00+09:00", Type: "buy", AmountJPY: 1000000, Units: 1000000},
+	}
+	var prices []strategy.DailyPrice
+	price := 10000.0
+	for d := day("2024-01-04"); d.Before(day("2024-07-01")); d = d.AddDate(0, 0, 1) {
+		// 上昇と下落を繰り返す価格 (ブロック・ブートストラップの標本)
+		price *= 1 + 0.01*math.Sin(float64(d.YearDay()))
+		prices = append(prices, strategy.DailyPrice{Date: d.Format("2006-01-02"), Price: int(math.Round(price))})
+	}
+	return transactions, prices
+}
+
+func TestSimulateDeterministic(t *testing.T) {
+	transactions, prices := simInput()
+	models := []ReturnModel{
+		Normal{Mean: 0.04, Vol: 0.2},
+		BlockBootstrap{Returns: LogReturns(prices), Block: 20},
+	}
+	for _, model := range models {
+		t.Run(model.String(), func(t *testing.T) {
+			cfg := SimConfig{
+				Start: day("2024-06-30"),
+				Years: 2,
+				Paths: 16,
+				Seed:  42,
+				Model: model,
+				Floor: 100000,
+			}
+			run := func(seed uint64, jobs int) *SimResult {
+				cfg.Seed, cfg.Jobs = seed, jobs
+				res, err := Simulate("fixed_rate", transactions, prices, cfg)
+				if err != nil {
+					t.Fatalf("予期しないエラー: %v", err)
+				}
+				return res
+			}
+
+			serial := run(42, 1)
+			if serial.Outcomes[0].Trades == 0 {
+				t.Fatalf("売却が1度もありません: %+v", serial.Outcomes[0])
+			}
+			// 並列数によらず, 同じ種からは同じ結果になる
+			for _, jobs := range []int{1, 4} {
+				if got := run(42, jobs); !reflect.DeepEqual(got.Outcomes, serial.Outcomes) {
+					t.Errorf("並列数 %d で結果が変わりました", jobs)
+				}
+			}
+			if got := run(43, 1); reflect.DeepEqual(got.Outcomes, serial.Outcomes) {
+				t.Errorf("種を変えても結果が変わりません")
+			}
+			// 経路ごとに異なる乱数を使う
+			if reflect.DeepEqual(serial.Outcomes[0], serial.Outcomes[1]) {
+				t.Errorf("経路 0 と 1 の結果が同じです")
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{10, 1},
+		{50, 3},
+		{90, 5},
+		{100, 5},
+	}
+	for _, tt := range tests {
+		if got := Percentile(values, tt.p); got != tt.want {
+			t.Errorf("Percentile(%g): got %g, want %g", tt.p, got, tt.want)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("空の値: got %g, want 0", got)
+	}
+}
@@ -0,0 +1,273 @@
+// internal/backtest/sweep.go
+package backtest
+
+import (
+	"fmt"
+	"kk-invest/internal/data"
+	"kk-invest/internal/strategy"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 探索の目的
+type Objective string
+
+const (
+	ObjectiveProceeds Objective = "proceeds" // 売却代金の合計が大きい順
+	ObjectiveDrawdown Objective = "drawdown" // 最大下落率が小さい順
+	ObjectiveVariance Objective = "variance" // 1回あたりの売却代金の分散が小さい順
+)
+
+var Objectives = []Objective{ObjectiveProceeds, ObjectiveDrawdown, ObjectiveVariance}
+
+func ParseObjective(s string) (Objective, error) {
+	for _, o := range Objectives {
+		if string(o) == s {
+			return o, nil
+		}
+	}
+	return "", fmt.Errorf("未知の目的です: %q (proceeds, drawdown, variance のいずれか)", s)
+}
+
+// 探索する引数と, その候補の値
+type Axis struct {
+	Name   string
+	Values []string
+}
+
+// 探索の1つの組み合わせの結果
+type SweepResult struct {
+	Params   map[string]string // 探索した引数の値
+	Result   *Result
+	Variance float64 // 1回あたりの売却代金の分散
+	Err      error
+}
+
+// 上限を超える組み合わせは誤指定とみなす
+const maxCombinations = 100000
+
+// "name=start:stop:step" または "name=a|b|c" 形式の指定を解析する
+// name は引数名のほか, 引数名を _ で区切った一部 (例: sell_ratio に対する ratio) でも指定できる
+func ParseGrid(specs []strategy.ParamSpec, flags []string) ([]Axis, error) {
+	var axes []Axis
+	seen := make(map[string]bool)
+	for _, f := range flags {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || strings.TrimSpace(key) == "" || value == "" {
+			return nil, fmt.Errorf("探索範囲は name=start:stop:step または name=a|b|c の形式で指定してください: %q", f)
+		}
+		name, err := resolveParamName(specs, strings.TrimSpace(key))
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("引数 %s の探索範囲が重複しています", name)
+		}
+		seen[name] = true
+
+		// 値に : を含む列挙 (schedule=weekly:sun|bizday:1 など) もあるため, 先に | で区切る
+		// 区切りがなく, : で区切った3つが全て数値の場合だけを範囲とみなす
+		values := strings.Split(value, "|")
+		if parts := strings.Split(value, ":"); len(values) == 1 && len(parts) == 3 && allNumbers(parts) {
+			values, err = numericRange(parts)
+			if err != nil {
+				return nil, fmt.Errorf("引数 %s: %w", name, err)
+			}
+		}
+		axes = append(axes, Axis{Name: name, Values: values})
+	}
+
+	total := 1
+	for _, a := range axes {
+		total *= len(a.Values)
+		if total > maxCombinations {
+			return nil, fmt.Errorf("組み合わせが多すぎます (上限: %d)", maxCombinations)
+		}
+	}
+	return axes, nil
+}
+
+func resolveParamName(specs []strategy.ParamSpec, key string) (string, error) {
+	var names, matches []string
+	for _, spec := range specs {
+		if spec.Name == key {
+			return key, nil
+		}
+		names = append(names, spec.Name)
+		for _, part := range strings.Split(spec.Name, "_") {
+			if part == key {
+				matches = append(matches, spec.Name)
+				break
+			}
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return "", fmt.Errorf("引数 %q は定義されていません (%s)", key, strings.Join(names, ", "))
+	}
+	return "", fmt.Errorf("引数 %q が複数の引数に該当します (%s)", key, strings.Join(matches, ", "))
+}
+
+func allNumbers(parts []string) bool {
+	for _, p := range parts {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(p), 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func numericRange(parts []string) ([]string, error) {
+	var nums [3]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("start:stop:step は数値で指定してください: %q", p)
+		}
+		nums[i] = f
+	}
+	start, stop, step := nums[0], nums[1], nums[2]
+	if step <= 0 {
+		return nil, fmt.Errorf("step は正の数で指定してください: %v", step)
+	}
+	if stop < start {
+		return nil, fmt.Errorf("stop は start 以上で指定してください: %v < %v", stop, start)
+	}
+
+	var values []string
+	// 浮動小数点の誤差で stop が範囲外にならないよう, わずかに余裕を持たせる
+	for i := 0; ; i++ {
+		v := start + float64(i)*step
+		if v > stop+step*1e-9 {
+			break
+		}
+		if len(values) >= maxCombinations {
+			return nil, fmt.Errorf("値が多すぎます (上限: %d)", maxCombinations)
+		}
+		v = math.Round(v*1e9) / 1e9
+		values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return values, nil
+}
+
+// 探索範囲の全ての組み合わせ
+func Combinations(axes []Axis) []map[string]string {
+	combos := []map[string]string{{}}
+	for _, a := range axes {
+		var next []map[string]string
+		for _, c := range combos {
+			for _, v := range a.Values {
+				m := make(map[string]string, len(c)+1)
+				for k, x := range c {
+					m[k] = x
+				}
+				m[a.Name] = v
+				next = append(next, m)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// 探索範囲の全ての組み合わせでバックテストを並列に実行する
+// 引数は既定値に layers, 組み合わせの値の順に重ねる. 結果は組み合わせの順に返す
+func Sweep(name string, layers []map[string]string, axes []Axis, transactions []data.Transaction, prices []strategy.DailyPrice, from, to time.Time, jobs int) []SweepResult {
+	combos := Combinations(axes)
+	results := make([]SweepResult, len(combos))
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = runCombination(name, layers, combos[i], transactions, prices, from, to)
+			}
+		}()
+	}
+	for i := range combos {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func runCombination(name string, layers []map[string]string, combo map[string]string, transactions []data.Transaction, prices []strategy.DailyPrice, from, to time.Time) SweepResult {
+	r := SweepResult{Params: combo}
+	s, _, err := strategy.Build(name, append(append([]map[string]string{}, layers...), combo)...)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Result, r.Err = Run(s, transactions, prices, from, to)
+	if r.Err == nil {
+		r.Variance = proceedsVariance(r.Result.Trades)
+	}
+	return r
+}
+
+// 1回あたりの売却代金の分散 (母分散)
+func proceedsVariance(trades []Trade) float64 {
+	if len(trades) < 2 {
+		return 0
+	}
+	var sum float64
+	for _, t := range trades {
+		sum += float64(t.Proceeds)
+	}
+	mean := sum / float64(len(trades))
+	var sq float64
+	for _, t := range trades {
+		d := float64(t.Proceeds) - mean
+		sq += d * d
+	}
+	return sq / float64(len(trades))
+}
+
+// 目的に従って結果を並べ替える
+// 失敗した組み合わせと売却が1回もない組み合わせは末尾に置き, 同順位は売却代金の多い順とする
+func Rank(results []SweepResult, objective Objective) error {
+	var better func(a, b *SweepResult) bool
+	switch objective {
+	case ObjectiveProceeds:
+		better = func(a, b *SweepResult) bool { return false }
+	case ObjectiveDrawdown:
+		better = func(a, b *SweepResult) bool { return a.Result.MaxDrawdown < b.Result.MaxDrawdown }
+	case ObjectiveVariance:
+		better = func(a, b *SweepResult) bool { return a.Variance < b.Variance }
+	default:
+		_, err := ParseObjective(string(objective))
+		return err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := &results[i], &results[j]
+		aOK, bOK := a.Err == nil && len(a.Result.Trades) > 0, b.Err == nil && len(b.Result.Trades) > 0
+		if aOK != bOK {
+			return aOK
+		}
+		if !aOK {
+			return false
+		}
+		if better(a, b) {
+			return true
+		}
+		if better(b, a) {
+			return false
+		}
+		return a.Result.Proceeds > b.Result.Proceeds
+	})
+	return nil
+}
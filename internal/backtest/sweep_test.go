@@ -0,0 +1,93 @@
+// internal/backtest/sweep_test.go
+package backtest
+
+import (
+	"kk-invest/internal/strategy"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var gridSpecs = []strategy.ParamSpec{
+	{Name: "sell_ratio", Type: strategy.ParamFloat},
+	{Name: "annual_rate", Type: strategy.ParamFloat},
+	{Name: "schedule", Type: strategy.ParamString},
+	{Name: "min_order_jpy", Type: strategy.ParamFloat},
+}
+
+func TestParseGrid(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		want  []Axis
+		err   string
+	}{
+		{
+			name:  "数値の範囲",
+			flags: []string{"sell_ratio=0.1:0.3:0.1"},
+			want:  []Axis{{Name: "sell_ratio", Values: []string{"0.1", "0.2", "0.3"}}},
+		},
+		{
+			name:  "列挙",
+			flags: []string{"annual_rate=0.03|0.04"},
+			want:  []Axis{{Name: "annual_rate", Values: []string{"0.03", "0.04"}}},
+		},
+		{
+			name:  "ヘルプの例: : を含む値の列挙",
+			flags: []string{"schedule=weekly:sun|bizday:1"},
+			want:  []Axis{{Name: "schedule", Values: []string{"weekly:sun", "bizday:1"}}},
+		},
+		{
+			name:  ": を2つ含むが数値でない値",
+			flags: []string{"schedule=weekly:fri every=2:x"},
+			want:  []Axis{{Name: "schedule", Values: []string{"weekly:fri every=2:x"}}},
+		},
+		{
+			name:  "引数名の一部",
+			flags: []string{"ratio=0.5", "order=100|1000"},
+			want: []Axis{
+				{Name: "sell_ratio", Values: []string{"0.5"}},
+				{Name: "min_order_jpy", Values: []string{"100", "1000"}},
+			},
+		},
+		{name: "step が 0", flags: []string{"sell_ratio=0.1:0.3:0"}, err: "step は正の数"},
+		{name: "stop が start より小さい", flags: []string{"sell_ratio=0.3:0.1:0.1"}, err: "stop は start 以上"},
+		{name: "形式の誤り", flags: []string{"sell_ratio"}, err: "形式で指定してください"},
+		{name: "未定義の引数", flags: []string{"foo=1"}, err: "定義されていません"},
+		{name: "重複", flags: []string{"sell_ratio=0.1", "ratio=0.2"}, err: "重複"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGrid(gridSpecs, tt.flags)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("エラー %q を期待しましたが, %v でした", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("予期しないエラー: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCombinations(t *testing.T) {
+	combos := Combinations([]Axis{
+		{Name: "a", Values: []string{"1", "2"}},
+		{Name: "b", Values: []string{"x", "y", "z"}},
+	})
+	if len(combos) != 6 {
+		t.Fatalf("組み合わせの数: got %d, want 6", len(combos))
+	}
+	seen := make(map[string]bool)
+	for _, c := range combos {
+		seen[c["a"]+c["b"]] = true
+	}
+	if len(seen) != 6 {
+		t.Errorf("重複した組み合わせがあります: %v", combos)
+	}
+}
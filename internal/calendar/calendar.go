@@ -0,0 +1,227 @@
+// internal/calendar/calendar.go
+package calendar
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// 内閣府の祝日一覧に基づく祝日・休日 (振替休日, 国民の休日を含む)
+//
+//go:embed holidays.csv
+var embeddedHolidays string
+
+// 組み込みの祝日データが収録している期間
+const (
+	FirstYear = 2016
+	LastYear  = 2030
+)
+
+const dateLayout = "2006-01-02"
+
+// 営業日カレンダー
+// 土日, 祝日, 年末年始 (12/31〜1/3) を休業日とし, 利用者のファイルで追加・取り消しができる
+type Calendar struct {
+	holidays map[int]string // 日付 (YYYYMMDD) -> 休業日の名称
+	workdays map[int]bool   // 休業日の規則より優先して営業日とする日付
+}
+
+var current = Embedded()
+
+// 組み込みの祝日データだけを使うカレンダー
+func Embedded() *Calendar {
+	c := &Calendar{
+		holidays: make(map[int]string),
+		workdays: make(map[int]bool),
+	}
+	if err := c.parse(strings.NewReader(embeddedHolidays)); err != nil {
+		panic(fmt.Sprintf("組み込みの祝日データが不正です: %v", err))
+	}
+	return c
+}
+
+// 現在のカレンダー (LoadUserFile で読み込んだ内容を含む)
+func Default() *Calendar {
+	return current
+}
+
+// 利用者の祝日ファイルを組み込みのデータに重ねて, 現在のカレンダーとする
+// required が false の場合, ファイルが存在しなければ何もしない
+//
+// ファイルは組み込みのデータと同じ "YYYY-MM-DD,名称" 形式で, 1行に1日を記述する
+// 日付の先頭に ! を付けた行は, 祝日や年末年始であっても営業日として扱う
+// 空行と # で始まる行は無視する
+func LoadUserFile(path string, required bool) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("祝日ファイルを開けません: %w", err)
+	}
+	defer f.Close()
+
+	c := Embedded()
+	if err := c.parse(f); err != nil {
+		return fmt.Errorf("祝日ファイル %s: %w", path, err)
+	}
+	current = c
+	return nil
+}
+
+func (c *Calendar) parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dateStr, name, _ := strings.Cut(line, ",")
+		dateStr = strings.TrimSpace(dateStr)
+		name = strings.TrimSpace(name)
+
+		workday := strings.HasPrefix(dateStr, "!")
+		dateStr = strings.TrimPrefix(dateStr, "!")
+		t, err := time.Parse(dateLayout, dateStr)
+		if err != nil {
+			return fmt.Errorf("%d行目: 日付が不正です: %q", lineNo, dateStr)
+		}
+		key := dateKey(t)
+
+		if workday {
+			c.workdays[key] = true
+			delete(c.holidays, key)
+			continue
+		}
+		if name == "" {
+			name = "休業日"
+		}
+		c.holidays[key] = name
+		delete(c.workdays, key)
+	}
+	return scanner.Err()
+}
+
+// t が休業日であればその名称を返す
+// 土日は対象外 (祝日と重なる場合はその名称を返す)
+func (c *Calendar) HolidayName(t time.Time) (string, bool) {
+	key := dateKey(t)
+	if c.workdays[key] {
+		return "", false
+	}
+	if name, ok := c.holidays[key]; ok {
+		return name, true
+	}
+	if isYearEnd(t) {
+		return "年末年始休業日", true
+	}
+	return "", false
+}
+
+// 市場の年末年始の休業日 (12/31〜1/3)
+func isYearEnd(t time.Time) bool {
+	return (t.Month() == time.December && t.Day() == 31) || (t.Month() == time.January && t.Day() <= 3)
+}
+
+// t が営業日かどうか
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if c.workdays[dateKey(t)] {
+		return true
+	}
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	_, holiday := c.HolidayName(t)
+	return !holiday
+}
+
+// 組み込みの祝日データが t の年を収録しているかどうか
+// 範囲外の年は土日と年末年始のみを休業日として扱う
+func Covers(t time.Time) bool {
+	return t.Year() >= FirstYear && t.Year() <= LastYear
+}
+
+// t 以前 (当日を含む) で最後の営業日
+func (c *Calendar) OnOrBefore(t time.Time) time.Time {
+	day := truncate(t)
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// t 以降 (当日を含む) で最初の営業日
+func (c *Calendar) OnOrAfter(t time.Time) time.Time {
+	day := truncate(t)
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// t より前の直近の営業日
+func (c *Calendar) PrevBusinessDay(t time.Time) time.Time {
+	return c.OnOrBefore(truncate(t).AddDate(0, 0, -1))
+}
+
+// t より後の直近の営業日
+func (c *Calendar) NextBusinessDay(t time.Time) time.Time {
+	return c.OnOrAfter(truncate(t).AddDate(0, 0, 1))
+}
+
+// t から n 営業日後 (n が負の場合は前) の日付
+// t 自体が休業日の場合は, 直後 (n が負の場合は直前) の営業日を起点とする
+func (c *Calendar) AddBusinessDays(t time.Time, n int) time.Time {
+	if n < 0 {
+		day := c.OnOrBefore(t)
+		for ; n < 0; n++ {
+			day = c.PrevBusinessDay(day)
+		}
+		return day
+	}
+	day := c.OnOrAfter(t)
+	for ; n > 0; n-- {
+		day = c.NextBusinessDay(day)
+	}
+	return day
+}
+
+// order の日に出した注文の約定日と受渡日
+// 約定日は注文日 (休業日の場合は翌営業日) から tradeLag 営業日後, 受渡日は約定日から settleLag 営業日後
+func (c *Calendar) Settlement(order time.Time, tradeLag, settleLag int) (trade, settle time.Time) {
+	trade = c.AddBusinessDays(order, tradeLag)
+	settle = c.AddBusinessDays(trade, settleLag)
+	return trade, settle
+}
+
+// year の休業日 (土日を除く) を日付順に返す
+func (c *Calendar) Holidays(year int) []Holiday {
+	var out []Holiday
+	for day := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local); day.Year() == year; day = day.AddDate(0, 0, 1) {
+		if name, ok := c.HolidayName(day); ok {
+			out = append(out, Holiday{Date: day, Name: name})
+		}
+	}
+	return out
+}
+
+// 休業日
+type Holiday struct {
+	Date time.Time
+	Name string
+}
+
+// 地域によらず暦の日付で比較するためのキー
+func dateKey(t time.Time) int {
+	y, m, d := t.Date()
+	return y*10000 + int(m)*100 + d
+}
+
+func truncate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -11,6 +11,58 @@ import (
 
 type Config struct {
 	DataPath string `json:"data_path"`
+
+	// decide で使う戦略の名前 (省略時は simple)
+	Strategy string `json:"strategy,omitempty"`
+
+	// 戦略ごとの引数 (戦略名 -> 引数名 -> 値)
+	StrategyParams map[string]map[string]any `json:"strategy_params,omitempty"`
+
+	// 家計簿アプリ向け出力の分類 (アプリ名 -> 取引種別 -> 分類)
+	HouseholdCategories map[string]map[string]HouseholdCategory `json:"household_categories,omitempty"`
+
+	// 祝日ファイルのパス (省略時は書類パスの holidays.csv があれば使う)
+	HolidayFile string `json:"holiday_file,omitempty"`
+
+	// 売却注文の約定日・受渡日の計算方法 (省略時は DefaultSettlement)
+	Settlement *Settlement `json:"settlement,omitempty"`
+
+	// 複数の戦略を組み合わせた戦略 (名前 -> 定義). 名前は strategy や --strategy で指定できる
+	Composites map[string]Composite `json:"composites,omitempty"`
+
+	// この日数より前の変更履歴と論理削除した取引を, コマンドの実行時に削除する (省略時や 0 の場合は削除しない)
+	// 変更履歴を削除すると, decide --date でその日の時点の取引を再現できなくなる
+	PurgeAfterDays int `json:"purge_after_days,omitempty"`
+}
+
+// 複数の戦略を組み合わせた戦略の定義
+type Composite struct {
+	Description string           `json:"description,omitempty"`
+	Combine     string           `json:"combine"` // min, max, and, or, sequence
+	Children    []CompositeChild `json:"children"`
+}
+
+// 組み合わせる戦略と, その引数
+// 引数は strategy_params ではなく params だけを使う
+type CompositeChild struct {
+	Strategy string         `json:"strategy"`
+	Params   map[string]any `json:"params,omitempty"`
+}
+
+// 売却注文の約定日・受渡日までの営業日数
+type Settlement struct {
+	TradeLag  int `json:"trade_lag"`  // 注文日から約定日まで
+	SettleLag int `json:"settle_lag"` // 約定日から受渡日まで
+}
+
+// 海外資産に投資する投資信託で一般的な日程 (翌営業日約定, 約定日から4営業日後に受渡)
+var DefaultSettlement = Settlement{TradeLag: 1, SettleLag: 4}
+
+// 家計簿アプリに取り込む際の分類
+type HouseholdCategory struct {
+	Category    string `json:"category"`    // 大項目 (Zaim: カテゴリ)
+	Subcategory string `json:"subcategory"` // 中項目 (Zaim: カテゴリの内訳)
+	Account     string `json:"account"`     // 保有金融機関 (Zaim: 支払元・入金先)
 }
 
 var cfg Config
@@ -18,6 +70,32 @@ var configFilePath string
 
 var ResolvedDataPath string
 
+// 設定ファイルのパス
+func FilePath() string {
+	return configFilePath
+}
+
+// 読み込まれた設定を返す
+func Current() Config {
+	return cfg
+}
+
+// 約定日・受渡日の計算方法
+func (c Config) SettlementDays() Settlement {
+	if c.Settlement == nil {
+		return DefaultSettlement
+	}
+	return *c.Settlement
+}
+
+// 祝日ファイルのパスと, そのファイルが必須かどうか (設定ファイルで明示した場合は必須)
+func HolidayFilePath() (string, bool) {
+	if cfg.HolidayFile != "" {
+		return cfg.HolidayFile, true
+	}
+	return filepath.Join(ResolvedDataPath, "holidays.csv"), false
+}
+
 func FindOrCreateDatePath() (bool, error) {
 	// 設定ファイルの探索
 	// OS標準の設定ディレクトリ
@@ -40,7 +118,7 @@ func FindOrCreateDatePath() (bool, error) {
 
 		if cfg.DataPath != "" {
 			ResolvedDataPath = cfg.DataPath
-			fmt.Printf("設定書類から書類パスを取得しました: %s\n", ResolvedDataPath)
+			fmt.Fprintf(os.Stderr, "設定書類から書類パスを取得しました: %s\n", ResolvedDataPath)
 			return false, nil
 		}
 	}
@@ -0,0 +1,242 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 自動バックアップで保持する世代数
+const DefaultBackupKeep = 10
+
+const backupPrefix = "invest-"
+
+// 自動バックアップのラベルの接頭辞 (pre-migrate, pre-merge など)
+const autoBackupLabelPrefix = "pre-"
+
+// バックアップの保存先ディレクトリ
+func BackupDir() string {
+	return filepath.Join(filepath.Dir(dbPath), "backups")
+}
+
+// データベースの一貫したスナップショットを dir に作成し, そのパスを返す
+// VACUUM INTO を使うため, 他のプロセスが開いている最中でも安全に取得できる
+// label が空でない場合はファイル名の末尾に付加する
+func Backup(dir, label string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	base := backupPrefix + time.Now().Format("20060102-150405")
+	if label != "" {
+		base += "-" + label
+	}
+	dest := filepath.Join(dir, base+".db")
+	// 同じ秒に複数回取得した場合は連番を付ける
+	for i := 2; fileExists(dest); i++ {
+		dest = filepath.Join(dir, fmt.Sprintf("%s-%d.db", base, i))
+	}
+
+	if _, err := DB.Exec("VACUUM INTO ?", dest); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return dest, nil
+}
+
+// 破壊的な操作の前に取得するバックアップ
+// ファイル名のラベルには pre- を付ける. 世代の管理は自動バックアップの中だけで行い, backup コマンドで作成したものは削除しない
+func AutoBackup(label string) (string, error) {
+	if !strings.HasPrefix(label, autoBackupLabelPrefix) {
+		label = autoBackupLabelPrefix + label
+	}
+	path, err := Backup(BackupDir(), label)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "バックアップを作成しました: %s\n", path)
+	if _, err := rotateBackups(BackupDir(), DefaultBackupKeep, true); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// dir 内の手動のバックアップ (自動バックアップを除く) を新しい順に keep 世代だけ残し, 削除したファイルを返す
+func RotateBackups(dir string, keep int) ([]string, error) {
+	return rotateBackups(dir, keep, false)
+}
+
+// auto が true の場合は自動バックアップ, false の場合はそれ以外のバックアップの世代を管理する
+func rotateBackups(dir string, keep int, auto bool) ([]string, error) {
+	all, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, path := range all {
+		if isAutoBackup(path) == auto {
+			backups = append(backups, path)
+		}
+	}
+	if keep < 1 || len(backups) <= keep {
+		return nil, nil
+	}
+
+	var removed []string
+	for _, path := range backups[:len(backups)-keep] {
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// 自動バックアップのファイルかどうか (invest-YYYYMMDD-HHMMSS-pre-*.db)
+func isAutoBackup(path string) bool {
+	rest := strings.TrimPrefix(filepath.Base(path), backupPrefix)
+	const stamp = len("20060102-150405")
+	return len(rest) > stamp && strings.HasPrefix(rest[stamp:], "-"+autoBackupLabelPrefix)
+}
+
+// dir 内のバックアップを古い順に返す
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	type backupFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []backupFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, backupPrefix) || filepath.Ext(name) != ".db" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, backupFile{filepath.Join(dir, name), info.ModTime()})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].modTime.Equal(files[j].modTime) {
+			return files[i].path < files[j].path
+		}
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	backups := make([]string, len(files))
+	for i, f := range files {
+		backups[i] = f.path
+	}
+	return backups, nil
+}
+
+// バックアップファイルが復元可能かどうかを検査し, スキーマのバージョンを返す
+func VerifyBackup(path string) (int, error) {
+	if !fileExists(path) {
+		return 0, fmt.Errorf("ファイルが存在しません: %s", path)
+	}
+
+	// パスに ? や # が含まれていても別のファイルを開かないよう, 絶対パスから URI を組み立てる
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+	dsn := url.URL{Scheme: "file", Path: filepath.ToSlash(abs), RawQuery: "mode=ro"}
+	db, err := sql.Open("sqlite3", dsn.String())
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return 0, fmt.Errorf("整合性チェックに失敗しました: %w", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("整合性チェックに失敗しました: %s", result)
+	}
+
+	for _, table := range []string{"transactions", "transaction_history", "daily_prices"} {
+		var name string
+		err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name)
+		if err != nil {
+			return 0, fmt.Errorf("テーブル %s が見つかりません: %w", table, err)
+		}
+	}
+
+	version, err := currentSchemaVersion(db)
+	if err != nil {
+		return 0, fmt.Errorf("スキーマのバージョンを取得できません: %w", err)
+	}
+	if version > SchemaVersion {
+		return version, fmt.Errorf("バックアップのスキーマ (v%d) がこのバージョンの対応範囲 (v%d) より新しいです", version, SchemaVersion)
+	}
+	return version, nil
+}
+
+// バックアップファイルで現在のデータベースを置き換える
+// 置き換え前に現在のデータベースをバックアップし, 置き換え後はデータベースを閉じる
+func RestoreBackup(path string) error {
+	if _, err := VerifyBackup(path); err != nil {
+		return err
+	}
+	if _, err := AutoBackup("pre-restore"); err != nil {
+		return fmt.Errorf("failed to back up before restore: %w", err)
+	}
+	if err := CloseDB(); err != nil {
+		return err
+	}
+
+	// 同じディレクトリに複製してから rename で差し替える
+	tmpPath := dbPath + ".restore"
+	if err := copyFile(path, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -4,7 +4,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,9 +15,15 @@ import (
 
 var DB *sql.DB
 
+// 現在のデータベースファイルのパス
+var dbPath string
+
 // データベースファイルを開いて初期設定
 func InitDB(dataDir string) error {
-	dbPath := filepath.Join(dataDir, "invest.db")
+	dbPath = filepath.Join(dataDir, "invest.db")
+
+	_, statErr := os.Stat(dbPath)
+	isNew := os.IsNotExist(statErr)
 
 	var err error
 	DB, err = sql.Open("sqlite3", dbPath)
@@ -28,88 +36,17 @@ func InitDB(dataDir string) error {
 		return err
 	}
 
-	// テーブルの作成
-	transactionsSchema := `
-	CREATE TABLE IF NOT EXISTS transactions (
-		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-		datetime TEXT NOT NULL,
-		type TEXT NOT NULL,
-		amount_jpy INTEGER NOT NULL,
-		units INTEGER NOT NULL,
-		deleted_at TEXT
-	);`
-
-	if _, err := DB.Exec(transactionsSchema); err != nil {
-		return fmt.Errorf("failed to create transactions table: %w", err)
-	}
-
-	historySchema := `
-	CREATE TABLE IF NOT EXISTS transaction_history (
-		history_id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-		transaction_id INTEGER NOT NULL,
-		changed_at TEXT NOT NULL,
-		operation_type TEXT NOT NULL,
-		details TEXT
-	);`
-
-	if _, err := DB.Exec(historySchema); err != nil {
-		return fmt.Errorf("failed to create transaction_history table: %w", err)
-	}
-
-	exists, err := columnExists("transactions", "deleted_at")
-	if err != nil {
-		return fmt.Errorf("failed to check deleted_at column: %w", err)
-	}
-	if !exists {
-		fmt.Println("Adding deleted_at column to transactions table...")
-		if _, err := DB.Exec("ALTER TABLE transactions ADD COLUMN deleted_at TEXT"); err != nil {
-			return fmt.Errorf("failed to add deleted_at column: %w", err)
-		}
-	}
-
-	createDailyPricesTableSQL := `
-	CREATE TABLE IF NOT EXISTS daily_prices (
-		date TEXT NOT NULL PRIMARY KEY,
-		price INTEGER NOT NULL
-	);`
-
-	if _, err := DB.Exec(createDailyPricesTableSQL); err != nil {
+	if err := createSchema(DB); err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func columnExists(tableName, columnName string) (bool, error) {
-	query := fmt.Sprintf("PRAGMA table_info(%s)", tableName)
-	rows, err := DB.Query(query)
-	if err != nil {
-		return false, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var cid int
-		var name string
-		var type_ string
-		var notnull int
-		var dflt_value interface{}
-		var pk int
-		if err := rows.Scan(&cid, &name, &type_, &notnull, &dflt_value, &pk); err != nil {
-			return false, err
-		}
-		if name == columnName {
-			// カラムが存在する場合
-			return true, nil
-		}
-	}
-	// カラムが存在しない場合
-	return false, nil
+	// 新規作成時はバックアップ対象がないため, そのまま移行する
+	return migrate(DB, !isNew)
 }
 
 // 新しい取引をデータベースに追加
 func AddTransaction(txType string, amount int, units int) error {
-	insertSQL := `INSERT INTO transactions (datetime, type, amount_jpy, units) VALUES (?, ?, ?, ?)`
+	insertSQL := `INSERT INTO transactions (datetime, type, amount_jpy, units, uid) VALUES (?, ?, ?, ?, ?)`
 
 	// SQLインジェクション対策のため、プリペアドステートメントを使用
 	stmt, err := DB.Prepare(insertSQL)
@@ -119,12 +56,13 @@ func AddTransaction(txType string, amount int, units int) error {
 	defer stmt.Close()
 
 	now := time.Now().Format(time.RFC3339)
-	_, err = stmt.Exec(now, txType, amount, units)
+	_, err = stmt.Exec(now, txType, amount, units, newUID())
 	return err
 }
 
 type Transaction struct {
 	ID        int
+	UID       string // 端末間で共通の識別子
 	Datetime  string
 	Type      string
 	AmountJPY int
@@ -133,7 +71,7 @@ type Transaction struct {
 
 // すべての取引を取得
 func GetAllTransactions() ([]Transaction, error) {
-	querySQL := `SELECT id, datetime, type, amount_jpy, units FROM transactions WHERE deleted_at IS NULL ORDER BY datetime ASC`
+	querySQL := `SELECT id, uid, datetime, type, amount_jpy, units FROM transactions WHERE deleted_at IS NULL ORDER BY datetime ASC`
 
 	rows, err := DB.Query(querySQL)
 	if err != nil {
@@ -144,12 +82,88 @@ func GetAllTransactions() ([]Transaction, error) {
 	var transactions []Transaction
 	for rows.Next() {
 		var tx Transaction
-		if err := rows.Scan(&tx.ID, &tx.Datetime, &tx.Type, &tx.AmountJPY, &tx.Units); err != nil {
+		if err := rows.Scan(&tx.ID, &tx.UID, &tx.Datetime, &tx.Type, &tx.AmountJPY, &tx.Units); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
+
+// asOf の日の終わりの時点で記録されていた取引を取得
+// その後に論理削除された取引を含め, その後の編集は変更履歴の変更前の値に戻す
+// 変更履歴が削除済みの古い編集は戻せないため, 現在の値のままになる
+func GetTransactionsAsOf(asOf time.Time) ([]Transaction, error) {
+	end := time.Date(asOf.Year(), asOf.Month(), asOf.Day()+1, 0, 0, 0, 0, asOf.Location())
+	after := func(s string) bool {
+		t, err := time.Parse(time.RFC3339, s)
+		return err == nil && !t.Before(end)
+	}
+
+	rows, err := DB.Query(`SELECT id, uid, datetime, type, amount_jpy, units, deleted_at FROM transactions ORDER BY datetime ASC`)
+	if err != nil {
+		return nil, err
+	}
+	var transactions []Transaction
+	byUID := make(map[string]int)
+	for rows.Next() {
+		var tx Transaction
+		var deletedAt sql.NullString
+		if err := rows.Scan(&tx.ID, &tx.UID, &tx.Datetime, &tx.Type, &tx.AmountJPY, &tx.Units, &deletedAt); err != nil {
+			rows.Close()
 			return nil, err
 		}
+		if deletedAt.Valid && !after(deletedAt.String) {
+			continue
+		}
+		byUID[tx.UID] = len(transactions)
 		transactions = append(transactions, tx)
 	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 新しい編集から順に戻す
+	rows, err = DB.Query(`SELECT COALESCE(transaction_uid, ''), changed_at, COALESCE(details, '') FROM transaction_history
+		WHERE operation_type = 'EDIT' ORDER BY changed_at DESC, history_id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uid, changedAt, details string
+		if err := rows.Scan(&uid, &changedAt, &details); err != nil {
+			return nil, err
+		}
+		i, ok := byUID[uid]
+		if !ok || !after(changedAt) {
+			continue
+		}
+		var d EditHistoryDetail
+		if json.Unmarshal([]byte(details), &d) != nil {
+			continue
+		}
+		tx := &transactions[i]
+		switch d.FieldName {
+		case "datetime":
+			tx.Datetime = d.OldValue
+		case "type":
+			tx.Type = d.OldValue
+		case "amount_jpy":
+			fmt.Sscan(d.OldValue, &tx.AmountJPY)
+		case "units":
+			fmt.Sscan(d.OldValue, &tx.Units)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Datetime < transactions[j].Datetime
+	})
 	return transactions, nil
 }
 
@@ -164,8 +178,11 @@ func CloseDB() error {
 type PortfolioStatus struct {
 	TotalInvestment int // 総投資額 (円)
 	TotalUnits      int // 総口数
+	CostBasis       int // 取得原価 (円, 移動平均法. 売却しても1口あたりの取得原価は変わらない)
+	Principal       int // 元本 (円, 売却代金は評価益から先に取り崩したものとみなし, 評価益を超えた分だけ減らす)
 	CurrentValue    int // 現在の評価額 (円)
 	UnrealizedPL    int // 評価損益 (円)
+	Cash            int // 現金残高 (円, 売却代金と分配金から手数料と引き出しを差し引いたもの)
 }
 
 func GetPortfolioStatus() (*PortfolioStatus, error) {
@@ -173,20 +190,70 @@ func GetPortfolioStatus() (*PortfolioStatus, error) {
 	if err != nil {
 		return nil, err
 	}
+	prices, err := GetAllDailyPrices()
+	if err != nil {
+		return nil, err
+	}
 
+	status := ComputePortfolioStatus(transactions)
+	if len(prices) > 0 {
+		status.Valuate(prices[len(prices)-1].Price)
+	}
+	return status, nil
+}
+
+// 取引履歴からポートフォリオ状況を計算 (評価額と評価損益は Valuate で計算する)
+func ComputePortfolioStatus(transactions []Transaction) *PortfolioStatus {
 	status := &PortfolioStatus{}
 	for _, tx := range transactions {
 		switch tx.Type {
 		case "buy":
-			status.TotalInvestment += tx.AmountJPY
-			status.TotalUnits += tx.Units
+			status.ApplyBuy(tx.AmountJPY, tx.Units)
 		case "sell":
-			status.TotalInvestment -= tx.AmountJPY
-			status.TotalUnits -= tx.Units
+			status.ApplySell(tx.AmountJPY, tx.Units)
+		case "distribution":
+			status.Cash += tx.AmountJPY
+		case "fee", "withdrawal":
+			status.Cash -= tx.AmountJPY
 		}
 	}
 
-	return status, nil
+	return status
+}
+
+// amount 円で units 口を購入した結果を反映する
+func (s *PortfolioStatus) ApplyBuy(amount, units int) {
+	s.TotalInvestment += amount
+	s.TotalUnits += units
+	s.CostBasis += amount
+	s.Principal += amount
+}
+
+// units 口を売却して amount 円を受け取った結果を反映する
+func (s *PortfolioStatus) ApplySell(amount, units int) {
+	if s.TotalUnits > 0 && units > 0 {
+		// 売却時の価格で評価した評価益を超えた分だけ元本を取り崩す
+		gain := max(s.TotalUnits*amount/units-s.Principal, 0)
+		s.Principal -= min(max(amount-gain, 0), s.Principal)
+		if units >= s.TotalUnits {
+			s.CostBasis = 0
+		} else {
+			s.CostBasis -= s.CostBasis * units / s.TotalUnits
+		}
+	}
+	s.TotalInvestment -= amount
+	s.TotalUnits -= units
+	s.Cash += amount
+	if s.TotalUnits <= 0 {
+		s.CostBasis = 0
+		s.Principal = 0
+	}
+}
+
+// 基準価額 price (1万口あたり) で評価額と評価損益を計算する
+func (s *PortfolioStatus) Valuate(price int) {
+	s.CurrentValue = s.TotalUnits * price / 10000
+	s.UnrealizedPL = s.CurrentValue - s.CostBasis
 }
 
 type DailyPrice struct {
@@ -208,9 +275,13 @@ func AddDailyPrice(date string, price int) error {
 }
 
 func GetAllDailyPrices() ([]DailyPrice, error) {
+	return getDailyPrices(DB)
+}
+
+func getDailyPrices(db *sql.DB) ([]DailyPrice, error) {
 	querySQL := `SELECT date, price FROM daily_prices ORDER BY date ASC`
 
-	rows, err := DB.Query(querySQL)
+	rows, err := db.Query(querySQL)
 	if err != nil {
 		return nil, err
 	}
@@ -264,10 +335,11 @@ func SoftDeleteTransactionByID(id int, reason string) error {
 		return err
 	}
 
-	historySQL := `INSERT INTO price_history (transaction_id, changed_at, operation_type, details) VALUES (?, ?, ?, ?)`
+	historySQL := `INSERT INTO transaction_history (transaction_id, transaction_uid, uid, changed_at, operation_type, details)
+		VALUES (?, (SELECT uid FROM transactions WHERE id = ?), ?, ?, ?, ?)`
 	detail := HistoryDetail{Reason: reason}
 	detailJSON, _ := json.Marshal(detail)
-	if _, err := tx.Exec(historySQL, id, now, "DELETE", string(detailJSON)); err != nil {
+	if _, err := tx.Exec(historySQL, id, id, newUID(), now, "DELETE", string(detailJSON)); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -275,20 +347,53 @@ func SoftDeleteTransactionByID(id int, reason string) error {
 	return tx.Commit()
 }
 
+// days 日より前の変更履歴と, 論理削除した取引を削除する
+// 設定ファイルの purge_after_days を指定した場合だけ, コマンドの実行時に呼び出される
+// 同期したことがある場合, 変更履歴は最後の同期より後のものを残す (相手の端末が同期で使うため)
 func PurgeOldRecords(days int) error {
 	purgeDate := time.Now().AddDate(0, 0, -days).Format(time.RFC3339)
+	historyPurgeDate := purgeDate
+	var lastMerge sql.NullString
+	if err := DB.QueryRow(`SELECT MAX(merged_at) FROM merge_log`).Scan(&lastMerge); err != nil {
+		return fmt.Errorf("failed to read merge log: %w", err)
+	}
+	if lastMerge.Valid && lastMerge.String < historyPurgeDate {
+		historyPurgeDate = lastMerge.String
+	}
 
-	historyPurgeSQL := `DELETE FROM price_history WHERE changed_at < ?`
-	if _, err := DB.Exec(historyPurgeSQL, purgeDate); err != nil {
+	// 削除対象がある場合のみ, 事前にバックアップを取得
+	var count int
+	countSQL := `SELECT
+		(SELECT COUNT(*) FROM transaction_history WHERE changed_at < ?) +
+		(SELECT COUNT(*) FROM transactions WHERE deleted_at IS NOT NULL AND deleted_at < ?)`
+	if err := DB.QueryRow(countSQL, historyPurgeDate, purgeDate).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count old records: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+	if _, err := AutoBackup("pre-purge"); err != nil {
+		return fmt.Errorf("failed to back up before purge: %w", err)
+	}
+
+	historyPurgeSQL := `DELETE FROM transaction_history WHERE changed_at < ?`
+	if _, err := DB.Exec(historyPurgeSQL, historyPurgeDate); err != nil {
 		return fmt.Errorf("failed to purge old history records: %w", err)
 	}
 
+	// 他の端末との同期で復活しないよう, 識別子を残しておく
+	tombstoneSQL := `INSERT OR IGNORE INTO transaction_tombstones (uid, purged_at)
+		SELECT uid, ? FROM transactions WHERE deleted_at IS NOT NULL AND deleted_at < ? AND uid IS NOT NULL`
+	if _, err := DB.Exec(tombstoneSQL, time.Now().Format(time.RFC3339), purgeDate); err != nil {
+		return fmt.Errorf("failed to record purged transactions: %w", err)
+	}
+
 	transactionPurgeSQL := `DELETE FROM transactions WHERE deleted_at IS NOT NULL AND deleted_at < ?`
 	if _, err := DB.Exec(transactionPurgeSQL, purgeDate); err != nil {
 		return fmt.Errorf("failed to purge old deleted transactions: %w", err)
 	}
 
-	fmt.Printf("Purged records older than %d days\n", days)
+	fmt.Fprintf(os.Stderr, "Purged records older than %d days\n", days)
 	return nil
 }
 
@@ -323,7 +428,7 @@ func UpdateTransaction(id int, updates map[string]interface{}, reason string) er
 		return fmt.Errorf("failed to update transaction: %w", err)
 	}
 
-	historySQL := `INSERT INTO transaction_history (transaction_id, changed_at, operation_type, details) VALUES (?, ?, ?, ?)`
+	historySQL := `INSERT INTO transaction_history (transaction_id, transaction_uid, uid, changed_at, operation_type, details) VALUES (?, ?, ?, ?, ?, ?)`
 	for field, newValue := range updates {
 		var oldValue any
 		switch field {
@@ -346,7 +451,7 @@ func UpdateTransaction(id int, updates map[string]interface{}, reason string) er
 		}
 		detailJSON, _ := json.Marshal(detail)
 
-		if _, err := tx.Exec(historySQL, id, now, "EDIT", string(detailJSON)); err != nil {
+		if _, err := tx.Exec(historySQL, id, oldTx.UID, newUID(), now, "EDIT", string(detailJSON)); err != nil {
 			tx.Rollback()
 			return fmt.Errorf("failed to insert history record: %w", err)
 		}
@@ -356,11 +461,11 @@ func UpdateTransaction(id int, updates map[string]interface{}, reason string) er
 }
 
 func getTransactionByID(id int, tx *sql.Tx) (*Transaction, error) {
-	querySQL := `SELECT id, datetime, type, amount_jpy, units FROM transactions WHERE id = ? AND deleted_at IS NULL`
+	querySQL := `SELECT id, uid, datetime, type, amount_jpy, units FROM transactions WHERE id = ? AND deleted_at IS NULL`
 	row := tx.QueryRow(querySQL, id)
 
 	var t Transaction
-	if err := row.Scan(&t.ID, &t.Datetime, &t.Type, &t.AmountJPY, &t.Units); err != nil {
+	if err := row.Scan(&t.ID, &t.UID, &t.Datetime, &t.Type, &t.AmountJPY, &t.Units); err != nil {
 		return nil, err
 	}
 	return &t, nil
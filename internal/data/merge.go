@@ -0,0 +1,461 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 同期の際に, 両方の端末で同じ項目が異なる値に変更されていたもの
+type MergeConflict struct {
+	TransactionID  int    // 手元の取引ID (基準価額の場合は 0)
+	TransactionUID string // 取引の識別子 (基準価額の場合は空)
+	Date           string // 基準価額の日付 (取引の場合は空)
+	Field          string // 変更された項目
+	LocalValue     string // 手元の値
+	OtherValue     string // 相手の値
+}
+
+// 同期の結果
+type MergeResult struct {
+	AddedTransactions int // 追加した取引の件数
+	AppliedEdits      int // 反映した編集の件数
+	AppliedDeletes    int // 反映した削除の件数
+	AddedPrices       int // 追加した基準価額の件数
+	Conflicts         int // 競合の件数
+	ResolvedToOther   int // 競合のうち相手の値を採用した件数
+}
+
+// 同期対象の取引 (論理削除済みのものを含む)
+type mergeTransaction struct {
+	Transaction
+	DeletedAt sql.NullString
+}
+
+type mergeHistory struct {
+	UID            string
+	TransactionUID string
+	ChangedAt      string
+	OperationType  string
+	Details        string
+}
+
+// 同期で照合する項目 (競合を確認する順)
+var mergeableFields = []string{"datetime", "type", "amount_jpy", "units"}
+
+// 別の端末のデータベースを手元のデータベースに統合する
+// 取引は識別子で, 基準価額は日付で照合し, 手元にないものを追加する
+// 両方にある取引は現在の値を照合し, 相手の論理削除と, 相手だけで編集された項目を反映する
+// 相手の端末で完全に削除された取引 (transaction_tombstones) は手元でも論理削除する
+// 両方で同じ項目が異なる値に編集されていた場合や, 変更履歴が残っておらずどちらの編集か判断できない場合は
+// resolve を呼び出し, true が返れば相手の値を採用する
+func MergeDatabase(otherPath string, resolve func(MergeConflict) bool) (*MergeResult, error) {
+	if _, err := VerifyBackup(otherPath); err != nil {
+		return nil, err
+	}
+
+	// 相手のデータベースは書き換えないよう, 複製してから最新のスキーマに移行する
+	tmp, err := os.CreateTemp("", "kk-invest-merge-*.db")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := copyFile(otherPath, tmp.Name()); err != nil {
+		return nil, fmt.Errorf("failed to copy database: %w", err)
+	}
+	other, err := sql.Open("sqlite3", tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer other.Close()
+	if err := migrate(other, false); err != nil {
+		return nil, err
+	}
+
+	otherTxs, err := loadMergeTransactions(other)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transactions: %w", err)
+	}
+	otherHistory, err := loadMergeHistory(other)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	otherPrices, err := getDailyPrices(other)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prices: %w", err)
+	}
+
+	localTxs, err := loadMergeTransactions(DB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transactions: %w", err)
+	}
+	localHistory, err := loadMergeHistory(DB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	localPrices, err := getDailyPrices(DB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prices: %w", err)
+	}
+	tombstones, err := loadTombstones(DB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tombstones: %w", err)
+	}
+	otherTombstones, err := loadTombstones(other)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tombstones: %w", err)
+	}
+
+	localByUID := make(map[string]*mergeTransaction)
+	for i := range localTxs {
+		localByUID[localTxs[i].UID] = &localTxs[i]
+	}
+	otherByUID := make(map[string]*mergeTransaction)
+	for i := range otherTxs {
+		otherByUID[otherTxs[i].UID] = &otherTxs[i]
+	}
+	localOnlyEdits := onlyEdits(localHistory, otherHistory)
+	otherOnlyEdits := onlyEdits(otherHistory, localHistory)
+	otherDeleteHistory := make(map[string]bool)
+	for _, h := range otherHistory {
+		if h.OperationType == "DELETE" {
+			otherDeleteHistory[h.TransactionUID] = true
+		}
+	}
+
+	result := &MergeResult{}
+
+	// 競合の確認は書き込みのトランザクションを開く前に済ませる
+	// 利用者の回答を待つ間, 他のプロセスがデータベースに書き込めなくなるのを避けるため
+	type fieldKey struct{ uid, field string }
+	txAnswers := make(map[fieldKey]bool)
+	for _, o := range otherTxs {
+		local, ok := localByUID[o.UID]
+		if _, purged := otherTombstones[o.UID]; !ok || purged || local.DeletedAt.Valid || o.DeletedAt.Valid {
+			continue
+		}
+		for _, field := range mergeableFields {
+			current := local.fieldValue(field)
+			target := o.fieldValue(field)
+			if current == target || localOnlyEdits[o.UID][field] != otherOnlyEdits[o.UID][field] {
+				continue
+			}
+			result.Conflicts++
+			useOther := resolve(MergeConflict{
+				TransactionID:  local.ID,
+				TransactionUID: local.UID,
+				Field:          field,
+				LocalValue:     current,
+				OtherValue:     target,
+			})
+			if useOther {
+				result.ResolvedToOther++
+			}
+			txAnswers[fieldKey{o.UID, field}] = useOther
+		}
+	}
+	localPriceByDate := make(map[string]int)
+	for _, p := range localPrices {
+		localPriceByDate[p.Date] = p.Price
+	}
+	priceAnswers := make(map[string]bool)
+	for _, p := range otherPrices {
+		if localPrice, ok := localPriceByDate[p.Date]; ok && localPrice != p.Price {
+			result.Conflicts++
+			useOther := resolve(MergeConflict{
+				Date:       p.Date,
+				Field:      "price",
+				LocalValue: strconv.Itoa(localPrice),
+				OtherValue: strconv.Itoa(p.Price),
+			})
+			if useOther {
+				result.ResolvedToOther++
+			}
+			priceAnswers[p.Date] = useOther
+		}
+	}
+
+	if _, err := AutoBackup("pre-merge"); err != nil {
+		return nil, fmt.Errorf("failed to back up before merge: %w", err)
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Format(time.RFC3339)
+
+	// 相手の端末で完全に削除された取引は, 手元でも論理削除する
+	// 識別子は手元にも残し, さらに別の端末と同期した際に復活しないようにする
+	tombstoneUIDs := make([]string, 0, len(otherTombstones))
+	for uid := range otherTombstones {
+		tombstoneUIDs = append(tombstoneUIDs, uid)
+	}
+	sort.Strings(tombstoneUIDs)
+	for _, uid := range tombstoneUIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO transaction_tombstones (uid, purged_at) VALUES (?, ?)`, uid, otherTombstones[uid]); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to record tombstone: %w", err)
+		}
+		tombstones[uid] = otherTombstones[uid]
+		if local, ok := localByUID[uid]; ok && !local.DeletedAt.Valid {
+			if err := softDeleteForMerge(tx, local, otherTombstones[uid], now, "同期: 相手の端末で完全に削除済み"); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			result.AppliedDeletes++
+		}
+	}
+
+	// 手元にない取引の追加
+	addedUIDs := make(map[string]bool)
+	for _, t := range otherTxs {
+		if _, ok := localByUID[t.UID]; ok {
+			continue
+		}
+		if _, ok := tombstones[t.UID]; ok {
+			continue
+		}
+		res, err := tx.Exec(`INSERT INTO transactions (uid, datetime, type, amount_jpy, units, deleted_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			t.UID, t.Datetime, t.Type, t.AmountJPY, t.Units, t.DeletedAt)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to insert transaction: %w", err)
+		}
+		id, _ := res.LastInsertId()
+		added := t
+		added.ID = int(id)
+		localByUID[t.UID] = &added
+		addedUIDs[t.UID] = true
+		result.AddedTransactions++
+	}
+
+	// 両方にある取引は現在の値を照合する
+	// 変更履歴は, 異なる値のどちらが編集されたものかを判断するためだけに使う.
+	// 古い変更履歴が削除されていて判断できない場合は競合として扱う
+	for _, o := range otherTxs {
+		local, ok := localByUID[o.UID]
+		if !ok || addedUIDs[o.UID] || local.DeletedAt.Valid {
+			continue
+		}
+		if o.DeletedAt.Valid {
+			reason := ""
+			if !otherDeleteHistory[o.UID] {
+				reason = "同期: 相手の端末で削除済み"
+			}
+			if err := softDeleteForMerge(tx, local, o.DeletedAt.String, now, reason); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			result.AppliedDeletes++
+			continue
+		}
+
+		for _, field := range mergeableFields {
+			current := local.fieldValue(field)
+			target := o.fieldValue(field)
+			if current == target {
+				continue
+			}
+			localEdited := localOnlyEdits[o.UID][field]
+			otherEdited := otherOnlyEdits[o.UID][field]
+			apply := otherEdited && !localEdited
+			if localEdited == otherEdited {
+				apply = txAnswers[fieldKey{o.UID, field}]
+			}
+			if !apply {
+				continue
+			}
+			if err := local.setFieldValue(field, target); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			updateSQL := fmt.Sprintf("UPDATE transactions SET %s = ? WHERE uid = ?", field)
+			if _, err := tx.Exec(updateSQL, target, local.UID); err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to apply edit: %w", err)
+			}
+			result.AppliedEdits++
+		}
+	}
+
+	// 相手だけにある変更履歴の複製
+	localHistoryUIDs := make(map[string]bool)
+	for _, h := range localHistory {
+		localHistoryUIDs[h.UID] = true
+	}
+	for _, h := range otherHistory {
+		local, ok := localByUID[h.TransactionUID]
+		if localHistoryUIDs[h.UID] || !ok {
+			// 手元で完全に削除済みの取引の履歴は複製しない
+			continue
+		}
+		historySQL := `INSERT INTO transaction_history (transaction_id, transaction_uid, uid, changed_at, operation_type, details) VALUES (?, ?, ?, ?, ?, ?)`
+		if _, err := tx.Exec(historySQL, local.ID, local.UID, h.UID, h.ChangedAt, h.OperationType, h.Details); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to insert history record: %w", err)
+		}
+	}
+
+	// 基準価額
+	for _, p := range otherPrices {
+		localPrice, ok := localPriceByDate[p.Date]
+		if ok && localPrice == p.Price {
+			continue
+		}
+		if ok {
+			if !priceAnswers[p.Date] {
+				continue
+			}
+		} else {
+			result.AddedPrices++
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO daily_prices (date, price) VALUES (?, ?)`, p.Date, p.Price); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to merge price: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO merge_log (merged_at, source) VALUES (?, ?)`, now, otherPath); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to record merge: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// history のうち other にない編集 (取引の識別子 -> 項目)
+func onlyEdits(history, other []mergeHistory) map[string]map[string]bool {
+	seen := make(map[string]bool)
+	for _, h := range other {
+		seen[h.UID] = true
+	}
+	edits := make(map[string]map[string]bool)
+	for _, h := range history {
+		if h.OperationType != "EDIT" || seen[h.UID] {
+			continue
+		}
+		var d EditHistoryDetail
+		if json.Unmarshal([]byte(h.Details), &d) != nil {
+			continue
+		}
+		if edits[h.TransactionUID] == nil {
+			edits[h.TransactionUID] = make(map[string]bool)
+		}
+		edits[h.TransactionUID][d.FieldName] = true
+	}
+	return edits
+}
+
+// 相手の削除を手元に反映する
+// reason を指定した場合は, 相手の変更履歴にない削除として手元の変更履歴にも記録する
+func softDeleteForMerge(tx *sql.Tx, local *mergeTransaction, deletedAt, now, reason string) error {
+	if _, err := tx.Exec(`UPDATE transactions SET deleted_at = ? WHERE uid = ?`, deletedAt, local.UID); err != nil {
+		return fmt.Errorf("failed to apply delete: %w", err)
+	}
+	local.DeletedAt = sql.NullString{String: deletedAt, Valid: true}
+	if reason == "" {
+		return nil
+	}
+	detailJSON, _ := json.Marshal(HistoryDetail{Reason: reason})
+	historySQL := `INSERT INTO transaction_history (transaction_id, transaction_uid, uid, changed_at, operation_type, details) VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.Exec(historySQL, local.ID, local.UID, newUID(), now, "DELETE", string(detailJSON)); err != nil {
+		return fmt.Errorf("failed to insert history record: %w", err)
+	}
+	return nil
+}
+
+func (t *mergeTransaction) fieldValue(field string) string {
+	switch field {
+	case "datetime":
+		return t.Datetime
+	case "type":
+		return t.Type
+	case "amount_jpy":
+		return strconv.Itoa(t.AmountJPY)
+	case "units":
+		return strconv.Itoa(t.Units)
+	}
+	return ""
+}
+
+func (t *mergeTransaction) setFieldValue(field, value string) error {
+	var err error
+	switch field {
+	case "datetime":
+		t.Datetime = value
+	case "type":
+		t.Type = value
+	case "amount_jpy":
+		t.AmountJPY, err = strconv.Atoi(value)
+	case "units":
+		t.Units, err = strconv.Atoi(value)
+	}
+	if err != nil {
+		return fmt.Errorf("取引 (%s) の %s の値が不正です: %w", t.UID, field, err)
+	}
+	return nil
+}
+
+func loadMergeTransactions(db *sql.DB) ([]mergeTransaction, error) {
+	rows, err := db.Query(`SELECT id, uid, datetime, type, amount_jpy, units, deleted_at FROM transactions ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txs []mergeTransaction
+	for rows.Next() {
+		var t mergeTransaction
+		if err := rows.Scan(&t.ID, &t.UID, &t.Datetime, &t.Type, &t.AmountJPY, &t.Units, &t.DeletedAt); err != nil {
+			return nil, err
+		}
+		txs = append(txs, t)
+	}
+	return txs, rows.Err()
+}
+
+func loadMergeHistory(db *sql.DB) ([]mergeHistory, error) {
+	rows, err := db.Query(`SELECT uid, COALESCE(transaction_uid, ''), changed_at, operation_type, COALESCE(details, '') FROM transaction_history ORDER BY history_id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []mergeHistory
+	for rows.Next() {
+		var h mergeHistory
+		if err := rows.Scan(&h.UID, &h.TransactionUID, &h.ChangedAt, &h.OperationType, &h.Details); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+// 完全に削除した取引の識別子と, 削除した日時
+func loadTombstones(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query(`SELECT uid, purged_at FROM transaction_tombstones`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tombstones := make(map[string]string)
+	for rows.Next() {
+		var uid, purgedAt string
+		if err := rows.Scan(&uid, &purgedAt); err != nil {
+			return nil, err
+		}
+		tombstones[uid] = purgedAt
+	}
+	return tombstones, rows.Err()
+}
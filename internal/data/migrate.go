@@ -0,0 +1,300 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// スキーマのバージョン (PRAGMA user_version に保存)
+const SchemaVersion = 3
+
+// スキーマの移行処理
+// version は移行後のバージョンで, 昇順に並べる
+var migrations = []struct {
+	version int
+	apply   func(tx *sql.Tx) error
+}{
+	{1, migrateAddDeletedAt},
+	{2, migrateAddUIDs},
+	{3, migrateAddMergeLog},
+}
+
+// 最初のバージョンのテーブルを作成
+// 以降の変更は migrations で行う
+func createSchema(db *sql.DB) error {
+	transactionsSchema := `
+	CREATE TABLE IF NOT EXISTS transactions (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		datetime TEXT NOT NULL,
+		type TEXT NOT NULL,
+		amount_jpy INTEGER NOT NULL,
+		units INTEGER NOT NULL,
+		deleted_at TEXT
+	);`
+
+	if _, err := db.Exec(transactionsSchema); err != nil {
+		return fmt.Errorf("failed to create transactions table: %w", err)
+	}
+
+	historySchema := `
+	CREATE TABLE IF NOT EXISTS transaction_history (
+		history_id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		transaction_id INTEGER NOT NULL,
+		changed_at TEXT NOT NULL,
+		operation_type TEXT NOT NULL,
+		details TEXT
+	);`
+
+	if _, err := db.Exec(historySchema); err != nil {
+		return fmt.Errorf("failed to create transaction_history table: %w", err)
+	}
+
+	createDailyPricesTableSQL := `
+	CREATE TABLE IF NOT EXISTS daily_prices (
+		date TEXT NOT NULL PRIMARY KEY,
+		price INTEGER NOT NULL
+	);`
+
+	if _, err := db.Exec(createDailyPricesTableSQL); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 保存されているスキーマのバージョンを取得
+func currentSchemaVersion(db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// 未適用の移行処理を順に適用
+// backup が true の場合, 適用前に自動でバックアップを取得する
+func migrate(db *sql.DB, backup bool) error {
+	version, err := currentSchemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("データベースのスキーマ (v%d) がこのバージョンの対応範囲 (v%d) より新しいです", version, SchemaVersion)
+	}
+	if version == SchemaVersion {
+		return nil
+	}
+
+	if backup {
+		if _, err := AutoBackup("pre-migrate"); err != nil {
+			return fmt.Errorf("failed to back up before migration: %w", err)
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := m.apply(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to migrate schema to v%d: %w", m.version, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", m.version)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateAddDeletedAt(tx *sql.Tx) error {
+	exists, err := columnExists(tx, "transactions", "deleted_at")
+	if err != nil {
+		return fmt.Errorf("failed to check deleted_at column: %w", err)
+	}
+	if !exists {
+		fmt.Println("Adding deleted_at column to transactions table...")
+		if _, err := tx.Exec("ALTER TABLE transactions ADD COLUMN deleted_at TEXT"); err != nil {
+			return fmt.Errorf("failed to add deleted_at column: %w", err)
+		}
+	}
+	return nil
+}
+
+// 複数の端末間で取引と変更履歴を照合するための識別子を追加
+// 既存の行には ID と登録時の日時から決まる識別子を割り当てるため, 同じデータベースを複製した後に
+// それぞれで移行しても, 複製前からある行には同じ識別子が付く
+// 金額などは複製後に編集されている場合があるため使わない. 日時が編集されている場合は変更履歴から登録時の日時を復元する
+// 基準価額は日付を端末間で共通の識別子とする
+func migrateAddUIDs(tx *sql.Tx) error {
+	for _, c := range []struct{ table, column string }{
+		{"transactions", "uid"},
+		{"transaction_history", "uid"},
+		{"transaction_history", "transaction_uid"},
+	} {
+		exists, err := columnExists(tx, c.table, c.column)
+		if err != nil {
+			return fmt.Errorf("failed to check %s column: %w", c.column, err)
+		}
+		if !exists {
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s TEXT", c.table, c.column)); err != nil {
+				return fmt.Errorf("failed to add %s column: %w", c.column, err)
+			}
+		}
+	}
+
+	// 取引の識別子
+	created, err := originalDatetimes(tx)
+	if err != nil {
+		return fmt.Errorf("failed to read edit history: %w", err)
+	}
+	rows, err := tx.Query(`SELECT id, datetime FROM transactions WHERE uid IS NULL`)
+	if err != nil {
+		return err
+	}
+	uids := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var datetime string
+		if err := rows.Scan(&id, &datetime); err != nil {
+			rows.Close()
+			return err
+		}
+		if original, ok := created[id]; ok {
+			datetime = original
+		}
+		uids[id] = contentUID(id, datetime)
+	}
+	rows.Close()
+	for id, uid := range uids {
+		if _, err := tx.Exec(`UPDATE transactions SET uid = ? WHERE id = ?`, uid, id); err != nil {
+			return err
+		}
+	}
+
+	// 変更履歴の識別子
+	if _, err := tx.Exec(`UPDATE transaction_history SET transaction_uid =
+		(SELECT uid FROM transactions WHERE transactions.id = transaction_history.transaction_id)
+		WHERE transaction_uid IS NULL`); err != nil {
+		return err
+	}
+	rows, err = tx.Query(`SELECT history_id, COALESCE(transaction_uid, ''), changed_at, operation_type, COALESCE(details, '') FROM transaction_history WHERE uid IS NULL`)
+	if err != nil {
+		return err
+	}
+	uids = make(map[int]string)
+	for rows.Next() {
+		var id int
+		var txUID, changedAt, op, details string
+		if err := rows.Scan(&id, &txUID, &changedAt, &op, &details); err != nil {
+			rows.Close()
+			return err
+		}
+		uids[id] = contentUID(id, txUID, changedAt, op, details)
+	}
+	rows.Close()
+	for id, uid := range uids {
+		if _, err := tx.Exec(`UPDATE transaction_history SET uid = ? WHERE history_id = ?`, uid, id); err != nil {
+			return err
+		}
+	}
+
+	indexes := []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_uid ON transactions (uid)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_transaction_history_uid ON transaction_history (uid)`,
+	}
+	for _, index := range indexes {
+		if _, err := tx.Exec(index); err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	// 完全に削除した取引の識別子 (同期で復活させないため)
+	tombstonesSchema := `
+	CREATE TABLE IF NOT EXISTS transaction_tombstones (
+		uid TEXT NOT NULL PRIMARY KEY,
+		purged_at TEXT NOT NULL
+	);`
+	if _, err := tx.Exec(tombstonesSchema); err != nil {
+		return fmt.Errorf("failed to create transaction_tombstones table: %w", err)
+	}
+
+	return nil
+}
+
+// 同期した日時の記録を追加
+// 最後の同期より後の変更履歴は, 相手の端末がまだ受け取っていない可能性があるため削除しない
+func migrateAddMergeLog(tx *sql.Tx) error {
+	mergeLogSchema := `
+	CREATE TABLE IF NOT EXISTS merge_log (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		merged_at TEXT NOT NULL,
+		source TEXT NOT NULL
+	);`
+	if _, err := tx.Exec(mergeLogSchema); err != nil {
+		return fmt.Errorf("failed to create merge_log table: %w", err)
+	}
+	return nil
+}
+
+// 日時が編集された取引の, 登録時の日時 (取引ID -> 最初の編集の変更前の値)
+func originalDatetimes(tx *sql.Tx) (map[int]string, error) {
+	rows, err := tx.Query(`SELECT transaction_id, COALESCE(details, '') FROM transaction_history
+		WHERE operation_type = 'EDIT' ORDER BY changed_at ASC, history_id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	created := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var details string
+		if err := rows.Scan(&id, &details); err != nil {
+			return nil, err
+		}
+		var d EditHistoryDetail
+		if json.Unmarshal([]byte(details), &d) != nil || d.FieldName != "datetime" {
+			continue
+		}
+		if _, ok := created[id]; !ok {
+			created[id] = d.OldValue
+		}
+	}
+	return created, rows.Err()
+}
+
+func columnExists(tx *sql.Tx, tableName, columnName string) (bool, error) {
+	query := fmt.Sprintf("PRAGMA table_info(%s)", tableName)
+	rows, err := tx.Query(query)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var name string
+		var type_ string
+		var notnull int
+		var dflt_value interface{}
+		var pk int
+		if err := rows.Scan(&cid, &name, &type_, &notnull, &dflt_value, &pk); err != nil {
+			return false, err
+		}
+		if name == columnName {
+			// カラムが存在する場合
+			return true, nil
+		}
+	}
+	// カラムが存在しない場合
+	return false, nil
+}
@@ -0,0 +1,30 @@
+package data
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+)
+
+// 新しい行に割り当てる識別子 (UUID v4)
+func newUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return formatUID(b)
+}
+
+// 既存の行に割り当てる, 内容から決まる識別子 (UUID v5 と同じ形式)
+func contentUID(fields ...any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%q", fields)))
+	var b [16]byte
+	copy(b[:], sum[:16])
+	b[6] = (b[6] & 0x0f) | 0x50
+	b[8] = (b[8] & 0x3f) | 0x80
+	return formatUID(b)
+}
+
+func formatUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
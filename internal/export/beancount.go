@@ -0,0 +1,60 @@
+// internal/export/beancount.go
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"kk-invest/internal/data"
+)
+
+// 取引履歴と基準価額を beancount 形式で書き出す
+// 取得原価は移動平均法で計算した総額を {{...}} で明示するため,
+// 保有口数の勘定は booking method "NONE" で開設する
+func WriteBeancount(w io.Writer, transactions []data.Transaction, prices []data.DailyPrice, acc Accounts) error {
+	entries, err := buildJournal(transactions, acc)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, `option "title" "kk-invest"`)
+	fmt.Fprintln(bw, `option "operating_currency" "JPY"`)
+	fmt.Fprintln(bw)
+
+	if open := firstDate(entries, prices); open != "" {
+		fmt.Fprintf(bw, "%s commodity %s\n", open, acc.Commodity)
+		fmt.Fprintf(bw, "%s open %s %s \"NONE\"\n", open, acc.Fund, acc.Commodity)
+		for _, account := range usedAccounts(entries) {
+			if account != acc.Fund {
+				fmt.Fprintf(bw, "%s open %s JPY\n", open, account)
+			}
+		}
+		fmt.Fprintln(bw)
+	}
+
+	for _, p := range prices {
+		fmt.Fprintf(bw, "%s price %s %s JPY\n", p.Date, acc.Commodity, unitPrice(p.Price))
+	}
+	if len(prices) > 0 {
+		fmt.Fprintln(bw)
+	}
+
+	for _, e := range entries {
+		fmt.Fprintf(bw, "%s * %q\n", e.date, e.narration)
+		for _, p := range e.postings {
+			if p.units != 0 {
+				cost := p.jpy
+				if cost < 0 {
+					cost = -cost
+				}
+				fmt.Fprintf(bw, "  %s  %d %s {{%d JPY}}\n", p.account, p.units, acc.Commodity, cost)
+			} else {
+				fmt.Fprintf(bw, "  %s  %d JPY\n", p.account, p.jpy)
+			}
+		}
+		fmt.Fprintln(bw)
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,143 @@
+// internal/export/household.go
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"kk-invest/internal/config"
+	"kk-invest/internal/data"
+	"strconv"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// 対応している家計簿アプリ
+const (
+	MoneyForward = "moneyforward"
+	Zaim         = "zaim"
+)
+
+// 取引種別ごとの既定の分類
+func DefaultHouseholdCategories(app string) map[string]config.HouseholdCategory {
+	switch app {
+	case Zaim:
+		return map[string]config.HouseholdCategory{
+			"buy":          {Category: "その他", Subcategory: "投資", Account: "クレジットカード"},
+			"sell":         {Category: "その他", Subcategory: "投資信託の売却", Account: "銀行口座"},
+			"distribution": {Category: "その他", Subcategory: "分配金", Account: "銀行口座"},
+			"fee":          {Category: "その他", Subcategory: "手数料", Account: "銀行口座"},
+		}
+	default:
+		return map[string]config.HouseholdCategory{
+			"buy":          {Category: "その他", Subcategory: "投資", Account: "クレジットカード"},
+			"sell":         {Category: "収入", Subcategory: "その他入金", Account: "銀行口座"},
+			"distribution": {Category: "収入", Subcategory: "その他入金", Account: "銀行口座"},
+			"fee":          {Category: "その他", Subcategory: "手数料", Account: "銀行口座"},
+		}
+	}
+}
+
+// 取引種別ごとの内容 (品目)
+var householdContents = map[string]string{
+	"buy":          "クレカ積立",
+	"sell":         "投資信託 売却",
+	"distribution": "投資信託 分配金",
+	"fee":          "投資信託 手数料",
+}
+
+// 取引を家計簿アプリの手入力用 CSV (Shift_JIS) で書き出す
+// categories で指定されていない取引種別は既定の分類を使う
+// 売却代金と分配金は収入, クレカ積立と手数料は支出として出力する
+func WriteHousehold(w io.Writer, app string, transactions []data.Transaction, categories map[string]config.HouseholdCategory) error {
+	if app != MoneyForward && app != Zaim {
+		return fmt.Errorf("対応していない家計簿アプリです: %s", app)
+	}
+
+	merged := DefaultHouseholdCategories(app)
+	for txType, c := range categories {
+		merged[txType] = c
+	}
+
+	// 表現できない文字は置き換えて出力する
+	sjis := encoding.ReplaceUnsupported(japanese.ShiftJIS.NewEncoder())
+	sw := sjis.Writer(w)
+	cw := csv.NewWriter(sw)
+	cw.UseCRLF = true
+
+	if app == MoneyForward {
+		cw.Write([]string{"計算対象", "日付", "内容", "金額（円）", "保有金融機関", "大項目", "中項目", "メモ", "振替", "ID"})
+	} else {
+		cw.Write([]string{"日付", "方法", "カテゴリ", "カテゴリの内訳", "支払元", "入金先", "品目", "メモ", "お店", "通貨", "収入", "支出", "振替", "残高調整", "通貨変換前の金額", "集計の設定"})
+	}
+
+	for _, tx := range transactions {
+		c, ok := merged[tx.Type]
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, tx.Datetime)
+		if err != nil {
+			return fmt.Errorf("取引 (ID: %d) の日時が不正です: %w", tx.ID, err)
+		}
+		income := tx.Type == "sell" || tx.Type == "distribution"
+		memo := fmt.Sprintf("kk-invest ID: %d", tx.ID)
+		if tx.Units > 0 {
+			memo += fmt.Sprintf(" (%d口)", tx.Units)
+		}
+
+		if app == MoneyForward {
+			amount := tx.AmountJPY
+			if !income {
+				amount = -amount
+			}
+			cw.Write([]string{
+				"1",
+				t.Format("2006/01/02"),
+				householdContents[tx.Type],
+				strconv.Itoa(amount),
+				c.Account,
+				c.Category,
+				c.Subcategory,
+				memo,
+				"0",
+				fmt.Sprintf("kk-invest-%d", tx.ID),
+			})
+			continue
+		}
+
+		method, from, to, in, out := "payment", c.Account, "", "0", strconv.Itoa(tx.AmountJPY)
+		if income {
+			method, from, to, in, out = "income", "", c.Account, strconv.Itoa(tx.AmountJPY), "0"
+		}
+		cw.Write([]string{
+			t.Format("2006-01-02"),
+			method,
+			c.Category,
+			c.Subcategory,
+			from,
+			to,
+			householdContents[tx.Type],
+			memo,
+			"",
+			"JPY",
+			in,
+			out,
+			"0",
+			"0",
+			"",
+			"常に集計に含める",
+		})
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	if c, ok := sw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
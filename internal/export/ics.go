@@ -0,0 +1,89 @@
+// internal/export/ics.go
+package export
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// カレンダーに書き出す予定
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Date        time.Time // 予定の日付 (AllDay でない場合は開始日時)
+	AllDay      bool
+	Duration    time.Duration // AllDay でない場合の長さ
+	RRule       string        // 繰り返し規則 (例: FREQ=MONTHLY;BYMONTHDAY=1)
+	Alarm       bool          // 開始時刻に通知するか
+}
+
+// 予定を iCalendar (RFC 5545) 形式で書き出す
+func WriteICS(w io.Writer, name string, events []Event) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	writeICSLine(bw, "BEGIN:VCALENDAR")
+	writeICSLine(bw, "VERSION:2.0")
+	writeICSLine(bw, "PRODID:-//kk-invest//kk-invest//JA")
+	writeICSLine(bw, "CALSCALE:GREGORIAN")
+	writeICSLine(bw, "X-WR-CALNAME:"+escapeICSText(name))
+
+	for _, e := range events {
+		writeICSLine(bw, "BEGIN:VEVENT")
+		writeICSLine(bw, "UID:"+e.UID)
+		writeICSLine(bw, "DTSTAMP:"+stamp)
+		if e.AllDay {
+			writeICSLine(bw, "DTSTART;VALUE=DATE:"+e.Date.Format("20060102"))
+			writeICSLine(bw, "DTEND;VALUE=DATE:"+e.Date.AddDate(0, 0, 1).Format("20060102"))
+		} else {
+			// 曜日の繰り返しがずれないよう, 端末の現地時刻 (floating time) で出力する
+			writeICSLine(bw, "DTSTART:"+e.Date.Format("20060102T150405"))
+			writeICSLine(bw, "DTEND:"+e.Date.Add(e.Duration).Format("20060102T150405"))
+		}
+		if e.RRule != "" {
+			writeICSLine(bw, "RRULE:"+e.RRule)
+		}
+		writeICSLine(bw, "SUMMARY:"+escapeICSText(e.Summary))
+		if e.Description != "" {
+			writeICSLine(bw, "DESCRIPTION:"+escapeICSText(e.Description))
+		}
+		if e.Alarm {
+			writeICSLine(bw, "BEGIN:VALARM")
+			writeICSLine(bw, "ACTION:DISPLAY")
+			writeICSLine(bw, "DESCRIPTION:"+escapeICSText(e.Summary))
+			writeICSLine(bw, "TRIGGER:PT0M")
+			writeICSLine(bw, "END:VALARM")
+		}
+		writeICSLine(bw, "END:VEVENT")
+	}
+
+	writeICSLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// 75 オクテットを超える行は, 文字の途中で切らないように折り返す
+func writeICSLine(w *bufio.Writer, line string) {
+	const limit = 75
+	width := 0
+	for len(line) > 0 {
+		r, size := utf8.DecodeRuneInString(line)
+		if width+size > limit {
+			w.WriteString("\r\n ")
+			// 継続行は先頭の空白を含めて数える
+			width = 1
+		}
+		w.WriteRune(r)
+		width += size
+		line = line[size:]
+	}
+	w.WriteString("\r\n")
+}
+
+func escapeICSText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return r.Replace(s)
+}
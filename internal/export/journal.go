@@ -0,0 +1,164 @@
+// internal/export/journal.go
+package export
+
+import (
+	"fmt"
+	"kk-invest/internal/data"
+	"sort"
+	"time"
+)
+
+// 複式簿記形式で出力する際の勘定科目と銘柄名
+type Accounts struct {
+	Commodity     string // 口数を表す銘柄名
+	Fund          string // 投資信託の保有口数
+	Card          string // クレカ積立の支払い元
+	Cash          string // 売却代金や分配金の受け取り先
+	Gains         string // 売却損益
+	Distributions string // 分配金
+	Fees          string // 手数料
+	Withdrawals   string // 生活費などの引き出し
+}
+
+func DefaultAccounts() Accounts {
+	return Accounts{
+		Commodity:     "KKFUND",
+		Fund:          "Assets:Invest:KKFund",
+		Card:          "Liabilities:CreditCard",
+		Cash:          "Assets:Bank",
+		Gains:         "Income:CapitalGains",
+		Distributions: "Income:Distributions",
+		Fees:          "Expenses:Fees",
+		Withdrawals:   "Expenses:Living",
+	}
+}
+
+// 1つの仕訳行
+// units が 0 でない場合, jpy はその口数の取得原価を表す
+type posting struct {
+	account string
+	units   int
+	jpy     int
+}
+
+type entry struct {
+	date      string
+	narration string
+	postings  []posting
+}
+
+// 取引履歴を仕訳に変換する
+// 売却時の取得原価は移動平均法で計算し, 円未満は四捨五入する
+func buildJournal(transactions []data.Transaction, acc Accounts) ([]entry, error) {
+	var entries []entry
+	var holdingUnits, holdingCost int
+
+	for _, tx := range transactions {
+		date, err := txDate(tx.Datetime)
+		if err != nil {
+			return nil, fmt.Errorf("取引 (ID: %d) の日時が不正です: %w", tx.ID, err)
+		}
+
+		e := entry{date: date}
+		switch tx.Type {
+		case "buy":
+			e.narration = fmt.Sprintf("購入 (ID: %d)", tx.ID)
+			e.postings = []posting{
+				{account: acc.Fund, units: tx.Units, jpy: tx.AmountJPY},
+				{account: acc.Card, jpy: -tx.AmountJPY},
+			}
+			holdingUnits += tx.Units
+			holdingCost += tx.AmountJPY
+		case "sell":
+			if tx.Units > holdingUnits {
+				return nil, fmt.Errorf("取引 (ID: %d) の売却口数が保有口数を超えています", tx.ID)
+			}
+			cost := holdingCost
+			if tx.Units < holdingUnits {
+				cost = int(float64(holdingCost)*float64(tx.Units)/float64(holdingUnits) + 0.5)
+			}
+			e.narration = fmt.Sprintf("売却 (ID: %d)", tx.ID)
+			e.postings = []posting{
+				{account: acc.Fund, units: -tx.Units, jpy: -cost},
+				{account: acc.Cash, jpy: tx.AmountJPY},
+				{account: acc.Gains, jpy: cost - tx.AmountJPY},
+			}
+			holdingUnits -= tx.Units
+			holdingCost -= cost
+		case "distribution":
+			e.narration = fmt.Sprintf("分配金 (ID: %d)", tx.ID)
+			e.postings = []posting{
+				{account: acc.Cash, jpy: tx.AmountJPY},
+				{account: acc.Distributions, jpy: -tx.AmountJPY},
+			}
+		case "fee":
+			e.narration = fmt.Sprintf("手数料 (ID: %d)", tx.ID)
+			e.postings = []posting{
+				{account: acc.Fees, jpy: tx.AmountJPY},
+				{account: acc.Cash, jpy: -tx.AmountJPY},
+			}
+		case "withdrawal":
+			e.narration = fmt.Sprintf("引き出し (ID: %d)", tx.ID)
+			e.postings = []posting{
+				{account: acc.Withdrawals, jpy: tx.AmountJPY},
+				{account: acc.Cash, jpy: -tx.AmountJPY},
+			}
+		default:
+			return nil, fmt.Errorf("取引 (ID: %d) の種別 %q は出力できません", tx.ID, tx.Type)
+		}
+
+		sum := 0
+		for _, p := range e.postings {
+			sum += p.jpy
+		}
+		if sum != 0 {
+			return nil, fmt.Errorf("取引 (ID: %d) の仕訳が貸借一致しません", tx.ID)
+		}
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].date < entries[j].date
+	})
+	return entries, nil
+}
+
+// 仕訳に使われる勘定科目を出現順に返す
+func usedAccounts(entries []entry) []string {
+	seen := make(map[string]bool)
+	var accounts []string
+	for _, e := range entries {
+		for _, p := range e.postings {
+			if !seen[p.account] {
+				seen[p.account] = true
+				accounts = append(accounts, p.account)
+			}
+		}
+	}
+	return accounts
+}
+
+// 仕訳と価格の中で最も古い日付
+func firstDate(entries []entry, prices []data.DailyPrice) string {
+	first := ""
+	if len(entries) > 0 {
+		first = entries[0].date
+	}
+	if len(prices) > 0 && (first == "" || prices[0].Date < first) {
+		first = prices[0].Date
+	}
+	return first
+}
+
+// 1万口あたりの基準価額を1口あたりの価格 (小数第4位まで) に変換
+func unitPrice(pricePer10000 int) string {
+	return fmt.Sprintf("%d.%04d", pricePer10000/10000, pricePer10000%10000)
+}
+
+func txDate(datetime string) (string, error) {
+	t, err := time.Parse(time.RFC3339, datetime)
+	if err != nil {
+		return "", err
+	}
+	return t.Format("2006-01-02"), nil
+}
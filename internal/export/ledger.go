@@ -0,0 +1,51 @@
+// internal/export/ledger.go
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"kk-invest/internal/data"
+)
+
+// 取引履歴と基準価額を ledger 形式 (hledger でも読み込み可能) で書き出す
+// 口数の取得原価は @@ (総額) で表す
+func WriteLedger(w io.Writer, transactions []data.Transaction, prices []data.DailyPrice, acc Accounts) error {
+	entries, err := buildJournal(transactions, acc)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "commodity %s\n", acc.Commodity)
+	fmt.Fprintln(bw, "commodity JPY")
+	for _, account := range usedAccounts(entries) {
+		fmt.Fprintf(bw, "account %s\n", account)
+	}
+	fmt.Fprintln(bw)
+
+	for _, p := range prices {
+		fmt.Fprintf(bw, "P %s %s %s JPY\n", p.Date, acc.Commodity, unitPrice(p.Price))
+	}
+	if len(prices) > 0 {
+		fmt.Fprintln(bw)
+	}
+
+	for _, e := range entries {
+		fmt.Fprintf(bw, "%s * %s\n", e.date, e.narration)
+		for _, p := range e.postings {
+			if p.units != 0 {
+				cost := p.jpy
+				if cost < 0 {
+					cost = -cost
+				}
+				fmt.Fprintf(bw, "    %s  %d %s @@ %d JPY\n", p.account, p.units, acc.Commodity, cost)
+			} else {
+				fmt.Fprintf(bw, "    %s  %d JPY\n", p.account, p.jpy)
+			}
+		}
+		fmt.Fprintln(bw)
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,271 @@
+// internal/rules/eval.go
+package rules
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// 規則から呼び出せる関数
+var Functions = []*Function{
+	{"sma", []string{"n"}, "直近 n日の基準価額の単純移動平均", func(env *Env, args []float64) (float64, error) {
+		prices, err := env.window("sma", args[0])
+		if err != nil {
+			return 0, err
+		}
+		sum := 0.0
+		for _, p := range prices {
+			sum += p
+		}
+		return sum / float64(len(prices)), nil
+	}},
+	{"high", []string{"n"}, "直近 n日の基準価額の最高値", func(env *Env, args []float64) (float64, error) {
+		prices, err := env.window("high", args[0])
+		if err != nil {
+			return 0, err
+		}
+		out := prices[0]
+		for _, p := range prices {
+			out = math.Max(out, p)
+		}
+		return out, nil
+	}},
+	{"low", []string{"n"}, "直近 n日の基準価額の最安値", func(env *Env, args []float64) (float64, error) {
+		prices, err := env.window("low", args[0])
+		if err != nil {
+			return 0, err
+		}
+		out := prices[0]
+		for _, p := range prices {
+			out = math.Min(out, p)
+		}
+		return out, nil
+	}},
+	{"change", []string{"n"}, "n日前の基準価額からの騰落率 (%)", func(env *Env, args []float64) (float64, error) {
+		prices, err := env.window("change", args[0]+1)
+		if err != nil {
+			return 0, err
+		}
+		if prices[0] <= 0 {
+			return 0, fmt.Errorf("change(%g): %g日前の基準価額が 0 です", args[0], args[0])
+		}
+		return (prices[len(prices)-1]/prices[0] - 1) * 100, nil
+	}},
+	{"min", []string{"a", "b"}, "小さい方の値", func(env *Env, args []float64) (float64, error) {
+		return math.Min(args[0], args[1]), nil
+	}},
+	{"max", []string{"a", "b"}, "大きい方の値", func(env *Env, args []float64) (float64, error) {
+		return math.Max(args[0], args[1]), nil
+	}},
+	{"abs", []string{"x"}, "絶対値", func(env *Env, args []float64) (float64, error) {
+		return math.Abs(args[0]), nil
+	}},
+	{"floor", []string{"x"}, "切り捨て", func(env *Env, args []float64) (float64, error) {
+		return math.Floor(args[0]), nil
+	}},
+	{"round", []string{"x"}, "四捨五入", func(env *Env, args []float64) (float64, error) {
+		return math.Round(args[0]), nil
+	}},
+}
+
+// 直近 n日の基準価額
+func (env *Env) window(name string, n float64) ([]float64, error) {
+	if n < 1 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("%s の日数は 1 以上の整数で指定してください: %g", name, n)
+	}
+	if float64(len(env.Prices)) < n {
+		return nil, fmt.Errorf("%s(%g): 基準価額が %d日分しかありません", name, n, len(env.Prices))
+	}
+	return env.Prices[len(env.Prices)-int(n):], nil
+}
+
+// 規則を上から評価し, 最初に実行された動作を返す
+// 実行時のエラー (0 での割り算や基準価額の不足など) はその行のエラーとして返す
+func (p *Program) Evaluate(env *Env) (Action, error) {
+	ev := &evaluator{env: env, locals: make(map[string]any)}
+	for _, s := range p.stmts {
+		ev.line = s.line
+		if s.action == nil {
+			v, err := ev.eval(s.expr)
+			if err != nil {
+				return Action{}, err
+			}
+			ev.locals[s.name] = v
+			continue
+		}
+		if s.cond != nil {
+			v, err := ev.eval(s.cond)
+			if err != nil {
+				return Action{}, err
+			}
+			if !v.(bool) {
+				continue
+			}
+		}
+		return ev.action(s.action)
+	}
+	return Action{}, nil
+}
+
+type evaluator struct {
+	env    *Env
+	locals map[string]any
+	line   int
+}
+
+func (ev *evaluator) errorf(n *node, format string, args ...any) error {
+	return &Error{Line: ev.line, Col: n.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (ev *evaluator) action(a *actionCall) (Action, error) {
+	out := Action{Name: a.spec.Name, Note: a.note, Line: ev.line}
+	if a.amount == nil {
+		return out, nil
+	}
+	v, err := ev.eval(a.amount)
+	if err != nil {
+		return Action{}, err
+	}
+	out.Amount = v.(float64)
+	switch {
+	case out.Amount < 0:
+		return Action{}, ev.errorf(a.amount, "%s に負の値は指定できません: %g", a.spec.Name, out.Amount)
+	case a.spec.Name == "sell_pct" && out.Amount > 100:
+		return Action{}, ev.errorf(a.amount, "sell_pct の割合は 0〜100 で指定してください: %g", out.Amount)
+	}
+	return out, nil
+}
+
+func (ev *evaluator) number(n *node) (float64, error) {
+	v, err := ev.eval(n)
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+func (ev *evaluator) eval(n *node) (any, error) {
+	switch n.op {
+	case "num":
+		return n.num, nil
+	case "str":
+		return n.str, nil
+	case "bool":
+		return n.b, nil
+	case "local":
+		return ev.locals[n.name], nil
+	case "var":
+		v, ok := ev.env.Vars[n.name]
+		if !ok {
+			return nil, ev.errorf(n, "変数 %s の値がありません", n.name)
+		}
+		return v, nil
+	case "not":
+		v, err := ev.eval(n.args[0])
+		if err != nil {
+			return nil, err
+		}
+		return !v.(bool), nil
+	case "and", "or":
+		l, err := ev.eval(n.args[0])
+		if err != nil {
+			return nil, err
+		}
+		// 左辺で結果が決まる場合は右辺を評価しない (history >= 200 and price < sma(200) のように書ける)
+		if l.(bool) == (n.op == "or") {
+			return l, nil
+		}
+		return ev.eval(n.args[1])
+	case "neg":
+		v, err := ev.number(n.args[0])
+		return -v, err
+	case "call":
+		args := make([]float64, len(n.args))
+		for i, a := range n.args {
+			v, err := ev.number(a)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		v, err := n.fn.call(ev.env, args)
+		if err != nil {
+			return nil, ev.errorf(n, "%v", err)
+		}
+		return ev.finite(n, v)
+	}
+
+	l, err := ev.eval(n.args[0])
+	if err != nil {
+		return nil, err
+	}
+	r, err := ev.eval(n.args[1])
+	if err != nil {
+		return nil, err
+	}
+	if compareOps[n.op] {
+		return compare(n.op, l, r), nil
+	}
+
+	a, b := l.(float64), r.(float64)
+	switch n.op {
+	case "+":
+		return ev.finite(n, a+b)
+	case "-":
+		return ev.finite(n, a-b)
+	case "*":
+		return ev.finite(n, a*b)
+	case "/", "%":
+		if b == 0 {
+			return nil, ev.errorf(n, "0 で割ることはできません")
+		}
+		if n.op == "%" {
+			return math.Mod(a, b), nil
+		}
+		return ev.finite(n, a/b)
+	}
+	return nil, ev.errorf(n, "未知の演算子です: %s", n.op)
+}
+
+// 計算結果が有限の数値であることを確かめる
+func (ev *evaluator) finite(n *node, v float64) (any, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, ev.errorf(n, "計算結果が数値になりません")
+	}
+	return v, nil
+}
+
+// 同じ型の2つの値を比較する (型は読み込み時に確かめている)
+func compare(op string, l, r any) bool {
+	var c int
+	switch a := l.(type) {
+	case float64:
+		b := r.(float64)
+		switch {
+		case a < b:
+			c = -1
+		case a > b:
+			c = 1
+		}
+	case string:
+		c = strings.Compare(a, r.(string))
+	case bool:
+		if a != r.(bool) {
+			c = 1
+		}
+	}
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
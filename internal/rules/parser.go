@@ -0,0 +1,559 @@
+// internal/rules/parser.go
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOL tokenKind = iota // 行の終わり
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string // 識別子, 演算子, 文字列の中身
+	num  float64
+	col  int
+}
+
+var twoCharOps = map[string]bool{"==": true, "!=": true, "<=": true, ">=": true, "&&": true, "||": true}
+
+var compareOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// 1行を字句に分ける (最後は tokEOL)
+func tokenize(line string, lineNo int) ([]token, error) {
+	rs := []rune(line)
+	var out []token
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		col := i + 1
+		switch {
+		case r == '#':
+			i = len(rs)
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.' || rs[j] == '_') {
+				j++
+			}
+			text := string(rs[i:j])
+			f, err := strconv.ParseFloat(strings.ReplaceAll(text, "_", ""), 64)
+			if err != nil || strings.HasSuffix(text, "_") {
+				return nil, &Error{Line: lineNo, Col: col, Msg: fmt.Sprintf("数値が不正です: %q", text)}
+			}
+			out = append(out, token{kind: tokNumber, text: text, num: f, col: col})
+			i = j
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(rs) && (rs[j] == '_' || unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j])) {
+				j++
+			}
+			out = append(out, token{kind: tokIdent, text: string(rs[i:j]), col: col})
+			i = j
+		case r == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(rs) && rs[j] != '"'; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) && (rs[j+1] == '"' || rs[j+1] == '\\') {
+					j++
+				}
+				b.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, &Error{Line: lineNo, Col: col, Msg: "文字列が閉じられていません"}
+			}
+			out = append(out, token{kind: tokString, text: b.String(), col: col})
+			i = j + 1
+		default:
+			if i+1 < len(rs) {
+				if two := string(rs[i : i+2]); twoCharOps[two] {
+					out = append(out, token{kind: tokOp, text: two, col: col})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/%()<>,=!", r) {
+				return nil, &Error{Line: lineNo, Col: col, Msg: fmt.Sprintf("使えない文字です: %q", r)}
+			}
+			out = append(out, token{kind: tokOp, text: string(r), col: col})
+			i++
+		}
+	}
+	return append(out, token{kind: tokEOL, col: len(rs) + 1}), nil
+}
+
+// 文. let (name と expr), 条件付きの動作 (cond と action), 無条件の動作 (action) のいずれか
+type stmt struct {
+	line   int
+	name   string
+	expr   *node
+	cond   *node
+	action *actionCall
+}
+
+type actionCall struct {
+	spec   *ActionSpec
+	amount *node
+	note   string
+}
+
+// 式
+type node struct {
+	op   string // num, str, bool, var, local, call, neg, not, and, or, または二項演算子
+	typ  Type
+	line int
+	col  int
+	num  float64
+	str  string
+	b    bool
+	name string
+	fn   *Function
+	args []*node
+}
+
+// 式の入れ子の深さの上限
+const maxDepth = 64
+
+var keywords = map[string]bool{
+	"let": true, "if": true, "then": true, "and": true, "or": true, "not": true, "true": true, "false": true,
+}
+
+type parser struct {
+	line   int
+	toks   []token
+	pos    int
+	depth  int
+	locals map[string]Type
+	vars   map[string]Variable
+	used   map[string]bool
+	prog   *Program
+}
+
+// 規則を解析し, 変数や関数の名前と型を確かめる
+func Parse(src string) (*Program, error) {
+	p := &parser{
+		locals: make(map[string]Type),
+		vars:   make(map[string]Variable),
+		used:   make(map[string]bool),
+		prog:   &Program{},
+	}
+	for _, v := range Variables {
+		p.vars[v.Name] = v
+	}
+
+	for i, line := range strings.Split(src, "\n") {
+		toks, err := tokenize(strings.TrimSuffix(line, "\r"), i+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(toks) == 1 {
+			continue
+		}
+		p.line, p.toks, p.pos = i+1, toks, 0
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		p.prog.stmts = append(p.prog.stmts, s)
+	}
+
+	if _, rules := p.prog.Count(); rules == 0 {
+		return nil, fmt.Errorf("動作 (%s) が1つもありません", actionNames())
+	}
+	return p.prog, nil
+}
+
+func actionNames() string {
+	names := make([]string, len(Actions))
+	for i, a := range Actions {
+		names[i] = a.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOL {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &Error{Line: p.line, Col: t.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == text
+}
+
+func (p *parser) isKeyword(text string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && t.text == text
+}
+
+func (p *parser) expectOp(text string) error {
+	if !p.isOp(text) {
+		return p.errorf(p.peek(), "%q が必要です", text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) expectEOL() error {
+	if t := p.peek(); t.kind != tokEOL {
+		return p.errorf(t, "文の終わりに余分な記述があります: %q", t.text)
+	}
+	return nil
+}
+
+func (p *parser) statement() (*stmt, error) {
+	s := &stmt{line: p.line}
+	switch {
+	case p.isKeyword("let"):
+		p.next()
+		t := p.next()
+		if t.kind != tokIdent || keywords[t.text] {
+			return nil, p.errorf(t, "let の後には変数の名前が必要です")
+		}
+		if err := p.checkNewName(t); err != nil {
+			return nil, err
+		}
+		if err := p.expectOp("="); err != nil {
+			return nil, err
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		s.name, s.expr = t.text, e
+		p.locals[t.text] = e.typ
+	case p.isKeyword("if"):
+		p.next()
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if cond.typ != TypeBool {
+			return nil, &Error{Line: p.line, Col: cond.col, Msg: fmt.Sprintf("if の条件は真偽値にしてください (%sです)", cond.typ)}
+		}
+		if !p.isKeyword("then") {
+			return nil, p.errorf(p.peek(), "if の条件の後には then が必要です")
+		}
+		p.next()
+		if s.action, err = p.action(); err != nil {
+			return nil, err
+		}
+		s.cond = cond
+	default:
+		var err error
+		if s.action, err = p.action(); err != nil {
+			return nil, err
+		}
+	}
+	return s, p.expectEOL()
+}
+
+// 組み込みの名前や定義済みの変数と重ならないか
+func (p *parser) checkNewName(t token) error {
+	if _, ok := p.vars[t.text]; ok {
+		return p.errorf(t, "%s は組み込みの変数です", t.text)
+	}
+	if findFunction(t.text) != nil || findAction(t.text) != nil {
+		return p.errorf(t, "%s は組み込みの関数です", t.text)
+	}
+	if _, ok := p.locals[t.text]; ok {
+		return p.errorf(t, "変数 %s は既に定義されています", t.text)
+	}
+	return nil
+}
+
+func findFunction(name string) *Function {
+	for _, f := range Functions {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func findAction(name string) *ActionSpec {
+	for i := range Actions {
+		if Actions[i].Name == name {
+			return &Actions[i]
+		}
+	}
+	return nil
+}
+
+// 動作の呼び出し. 数値の引数 (hold 以外) と, 任意の説明の文字列
+func (p *parser) action() (*actionCall, error) {
+	t := p.next()
+	spec := findAction(t.text)
+	if t.kind != tokIdent || spec == nil {
+		return nil, p.errorf(t, "動作 (%s) が必要です", actionNames())
+	}
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	a := &actionCall{spec: spec}
+	if spec.amount {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if e.typ != TypeNumber {
+			return nil, &Error{Line: p.line, Col: e.col, Msg: fmt.Sprintf("%s の引数は数値にしてください (%sです)", spec.Name, e.typ)}
+		}
+		a.amount = e
+		if !p.isOp(",") {
+			return a, p.expectOp(")")
+		}
+		p.next()
+	}
+	if spec.amount || !p.isOp(")") {
+		s := p.next()
+		if s.kind != tokString {
+			return nil, p.errorf(s, "%s の説明は文字列 (\"...\") で指定してください", spec.Name)
+		}
+		a.note = s.text
+	}
+	return a, p.expectOp(")")
+}
+
+func (p *parser) expr() (*node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, p.errorf(p.peek(), "式の入れ子が深すぎます")
+	}
+	return p.or()
+}
+
+func (p *parser) binary(ops []string, operand func() (*node, error), check func(op string, l, r *node) (Type, error)) (*node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		op := t.text
+		switch {
+		case t.kind == tokOp && op == "&&":
+			op = "and"
+		case t.kind == tokOp && op == "||":
+			op = "or"
+		}
+		matched := false
+		for _, o := range ops {
+			if (t.kind == tokOp || t.kind == tokIdent) && op == o {
+				matched = true
+			}
+		}
+		if !matched {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		typ, err := check(op, left, right)
+		if err != nil {
+			return nil, p.errorf(t, "%v", err)
+		}
+		left = &node{op: op, typ: typ, line: p.line, col: left.col, args: []*node{left, right}}
+	}
+}
+
+func checkBool(op string, l, r *node) (Type, error) {
+	if l.typ != TypeBool || r.typ != TypeBool {
+		return 0, fmt.Errorf("%s は真偽値どうしに使ってください (%s と %sです)", op, l.typ, r.typ)
+	}
+	return TypeBool, nil
+}
+
+func checkNumber(op string, l, r *node) (Type, error) {
+	if l.typ != TypeNumber || r.typ != TypeNumber {
+		return 0, fmt.Errorf("%s は数値どうしに使ってください (%s と %sです)", op, l.typ, r.typ)
+	}
+	return TypeNumber, nil
+}
+
+func checkCompare(op string, l, r *node) (Type, error) {
+	if l.typ != r.typ {
+		return 0, fmt.Errorf("%s の両辺の型が異なります (%s と %sです)", op, l.typ, r.typ)
+	}
+	if l.typ == TypeBool && op != "==" && op != "!=" {
+		return 0, fmt.Errorf("真偽値は %s で比較できません", op)
+	}
+	return TypeBool, nil
+}
+
+func (p *parser) or() (*node, error) {
+	return p.binary([]string{"or"}, p.and, checkBool)
+}
+
+func (p *parser) and() (*node, error) {
+	return p.binary([]string{"and"}, p.not, checkBool)
+}
+
+func (p *parser) not() (*node, error) {
+	t := p.peek()
+	if p.isKeyword("not") || p.isOp("!") {
+		p.next()
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ != TypeBool {
+			return nil, p.errorf(t, "not は真偽値に使ってください (%sです)", operand.typ)
+		}
+		return &node{op: "not", typ: TypeBool, line: p.line, col: t.col, args: []*node{operand}}, nil
+	}
+	return p.compare()
+}
+
+// 比較は連ねられない (a < b < c はエラー)
+func (p *parser) compare() (*node, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp || !compareOps[t.text] {
+		return left, nil
+	}
+	p.next()
+	right, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	typ, err := checkCompare(t.text, left, right)
+	if err != nil {
+		return nil, p.errorf(t, "%v", err)
+	}
+	if n := p.peek(); n.kind == tokOp && compareOps[n.text] {
+		return nil, p.errorf(n, "比較は連ねられません. and で分けてください")
+	}
+	return &node{op: t.text, typ: typ, line: p.line, col: left.col, args: []*node{left, right}}, nil
+}
+
+func (p *parser) additive() (*node, error) {
+	return p.binary([]string{"+", "-"}, p.multiplicative, checkNumber)
+}
+
+func (p *parser) multiplicative() (*node, error) {
+	return p.binary([]string{"*", "/", "%"}, p.unary, checkNumber)
+}
+
+func (p *parser) unary() (*node, error) {
+	t := p.peek()
+	if p.isOp("-") {
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ != TypeNumber {
+			return nil, p.errorf(t, "- は数値に使ってください (%sです)", operand.typ)
+		}
+		return &node{op: "neg", typ: TypeNumber, line: p.line, col: t.col, args: []*node{operand}}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (*node, error) {
+	t := p.next()
+	n := &node{line: p.line, col: t.col}
+	switch t.kind {
+	case tokNumber:
+		n.op, n.typ, n.num = "num", TypeNumber, t.num
+		return n, nil
+	case tokString:
+		n.op, n.typ, n.str = "str", TypeString, t.text
+		return n, nil
+	case tokOp:
+		if t.text == "(" {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expectOp(")")
+		}
+	case tokIdent:
+		switch {
+		case t.text == "true" || t.text == "false":
+			n.op, n.typ, n.b = "bool", TypeBool, t.text == "true"
+			return n, nil
+		case keywords[t.text]:
+		case findAction(t.text) != nil:
+			return nil, p.errorf(t, "動作 %s は式の中では使えません. 文の先頭か then の後に書いてください", t.text)
+		case p.isOp("("):
+			return p.call(t, n)
+		default:
+			if typ, ok := p.locals[t.text]; ok {
+				n.op, n.typ, n.name = "local", typ, t.text
+				return n, nil
+			}
+			if v, ok := p.vars[t.text]; ok {
+				n.op, n.typ, n.name = "var", v.Type, t.text
+				if !p.used[t.text] {
+					p.used[t.text] = true
+					p.prog.used = append(p.prog.used, t.text)
+				}
+				return n, nil
+			}
+			return nil, p.errorf(t, "未知の変数です: %s", t.text)
+		}
+	}
+	if t.kind == tokEOL {
+		return nil, p.errorf(t, "式が途中で終わっています")
+	}
+	return nil, p.errorf(t, "式が必要な位置に %q があります", t.text)
+}
+
+// 関数の呼び出し (引数はいずれも数値)
+func (p *parser) call(t token, n *node) (*node, error) {
+	fn := findFunction(t.text)
+	if fn == nil {
+		return nil, p.errorf(t, "未知の関数です: %s", t.text)
+	}
+	p.next() // (
+	for !p.isOp(")") {
+		if len(n.args) > 0 {
+			if err := p.expectOp(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if arg.typ != TypeNumber {
+			return nil, &Error{Line: p.line, Col: arg.col, Msg: fmt.Sprintf("%s の引数は数値にしてください (%sです)", fn.Name, arg.typ)}
+		}
+		n.args = append(n.args, arg)
+	}
+	p.next() // )
+	if len(n.args) != len(fn.Params) {
+		return nil, p.errorf(t, "%s の引数は %d個です (%d個指定されています)", fn.Name, len(fn.Params), len(n.args))
+	}
+	n.op, n.typ, n.fn = "call", TypeNumber, fn
+	return n, nil
+}
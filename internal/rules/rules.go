@@ -0,0 +1,164 @@
+// internal/rules/rules.go
+package rules
+
+import (
+	"fmt"
+	"os"
+)
+
+// 売却判断の規則を記述する小さな言語
+//
+// 規則のファイルには1行に1つの文を書き, 上から順に評価する. # から行末まではコメント
+//
+//	let ma = sma(200)                                   変数の定義
+//	if history >= 200 and price < ma then hold("下落中") 条件を満たした場合の動作
+//	sell_pct(4 / sell_days_per_year)                    無条件の動作
+//
+// 最初に実行された動作で評価を終える. どの動作も実行されなかった場合は売却しない
+//
+// 動作: sell_pct(保有口数に対する割合 %), sell_jpy(金額), sell_units(口数), hold()
+// いずれも最後の引数に説明の文字列を指定でき, 売却判断の理由に表示される
+//
+// 値は数値, 真偽値 (true, false), 文字列 ("...") の3種類で, 型は読み込み時に確かめる
+// 演算子: + - * / %, < <= > >= == != (文字列どうしも比較できる), and or not (&& || ! とも書ける)
+//
+// 規則はファイルの読み書きや現在時刻, 乱数を参照できず, 繰り返しもないため, 同じ入力に対して常に同じ結果を返す
+type Program struct {
+	stmts []*stmt
+	used  []string // 規則が参照している組み込みの変数 (初めて現れた順)
+}
+
+// 値の型
+type Type int
+
+const (
+	TypeNumber Type = iota
+	TypeBool
+	TypeString
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeBool:
+		return "真偽値"
+	case TypeString:
+		return "文字列"
+	}
+	return "数値"
+}
+
+// 組み込みの変数
+type Variable struct {
+	Name        string
+	Type        Type
+	Description string
+}
+
+// 規則から参照できる変数. 値は評価のたびに Env.Vars で渡す
+var Variables = []Variable{
+	{"price", TypeNumber, "最新の基準価額 (1万口あたり)"},
+	{"history", TypeNumber, "記録された基準価額の日数"},
+	{"holdings", TypeNumber, "保有口数"},
+	{"value", TypeNumber, "評価額 (円)"},
+	{"cost", TypeNumber, "取得原価 (円)"},
+	{"principal", TypeNumber, "元本 (円). 売却代金のうち評価益を超える分だけ減る"},
+	{"gain", TypeNumber, "評価損益 (円). value - cost"},
+	{"invested", TypeNumber, "購入金額の合計 (円)"},
+	{"cash", TypeNumber, "現金残高 (円)"},
+	{"sold_this_year", TypeNumber, "基準日の年の売却代金の合計 (円)"},
+	{"date", TypeString, "基準日 (YYYY-MM-DD)"},
+	{"year", TypeNumber, "基準日の年"},
+	{"month", TypeNumber, "基準日の月 (1〜12)"},
+	{"day", TypeNumber, "基準日の日 (1〜31)"},
+	{"weekday", TypeNumber, "基準日の曜日 (0: 日曜日 〜 6: 土曜日)"},
+	{"sell_day", TypeBool, "基準日が売却日かどうか"},
+	{"next_sell_day", TypeString, "基準日以降の次の売却日 (YYYY-MM-DD, ない場合は空文字列)"},
+	{"sell_days_per_year", TypeNumber, "基準日の年の売却日の数"},
+	{"sell_day_index", TypeNumber, "基準日までの, その年の売却日の数 (基準日を含む)"},
+	{"sell_days_left", TypeNumber, "基準日からその年の終わりまでの売却日の数 (基準日を含む)"},
+}
+
+// 組み込みの関数
+type Function struct {
+	Name        string
+	Params      []string // 引数の名前 (いずれも数値)
+	Description string
+	call        func(env *Env, args []float64) (float64, error)
+}
+
+// 動作
+type ActionSpec struct {
+	Name        string
+	Description string
+	amount      bool // 数値の引数を取るか
+}
+
+// 規則から実行できる動作
+var Actions = []ActionSpec{
+	{"sell_pct", "保有口数のうち指定した割合 (%) を売却する (口数は切り捨て)", true},
+	{"sell_jpy", "指定した金額に相当する口数を売却する (口数は切り上げ)", true},
+	{"sell_units", "指定した口数を売却する", true},
+	{"hold", "売却しない", false},
+}
+
+// 規則の評価に使う値
+type Env struct {
+	Vars   map[string]any // 変数の名前 -> 値 (float64, bool, string)
+	Prices []float64      // 記録された基準価額 (古い順). sma などの関数が使う
+}
+
+// 評価の結果として実行された動作
+type Action struct {
+	Name   string  // 動作の名前 (どの動作も実行されなかった場合は空文字列)
+	Amount float64 // sell_pct の割合, sell_jpy の金額, sell_units の口数
+	Note   string  // 規則に書かれた説明
+	Line   int     // 動作が書かれた行
+}
+
+// 読み込みや評価のエラー
+type Error struct {
+	Line int
+	Col  int // 0 の場合は行だけを表示する
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Col > 0 {
+		return fmt.Sprintf("%d行目 %d文字目: %s", e.Line, e.Col, e.Msg)
+	}
+	return fmt.Sprintf("%d行目: %s", e.Line, e.Msg)
+}
+
+// 規則のファイルの大きさの上限
+const maxFileSize = 64 * 1024
+
+// 規則のファイルを読み込む
+func ParseFile(path string) (*Program, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("規則のファイルを読み込めません: %w", err)
+	}
+	if len(b) > maxFileSize {
+		return nil, fmt.Errorf("%s: 規則のファイルが大きすぎます (%d バイトまで)", path, maxFileSize)
+	}
+	p, err := Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// 文の数と, そのうち動作を含む文 (規則) の数
+func (p *Program) Count() (stmts, rules int) {
+	for _, s := range p.stmts {
+		if s.action != nil {
+			rules++
+		}
+	}
+	return len(p.stmts), rules
+}
+
+// 規則が参照している組み込みの変数 (初めて現れた順)
+func (p *Program) Used() []string {
+	return append([]string(nil), p.used...)
+}
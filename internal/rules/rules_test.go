@@ -0,0 +1,161 @@
+// internal/rules/rules_test.go
+package rules
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	src := `# 下落中は売らない
+let ma = sma(3)
+if history >= 3 and price < ma then hold("下落中")
+
+sell_pct(4 / sell_days_per_year)`
+	p, err := Parse(src)
+	if err != nil {
+		t.Fatalf("予期しないエラー: %v", err)
+	}
+	if stmts, rules := p.Count(); stmts != 3 || rules != 2 {
+		t.Errorf("文と規則の数: got %d, %d, want 3, 2", stmts, rules)
+	}
+	if got, want := p.Used(), []string{"history", "price", "sell_days_per_year"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("参照している変数: got %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"動作がない", "let x = 1", "動作 (sell_pct, sell_jpy, sell_units, hold) が1つもありません"},
+		{"未知の変数", "sell_pct(foo)", "未知の変数です: foo"},
+		{"未知の関数", "sell_pct(bar(1))", "未知の関数です: bar"},
+		{"引数の数", "sell_pct(sma(1, 2))", "sma の引数は 1個です"},
+		{"組み込みの変数の再定義", "let price = 1\nhold()", "price は組み込みの変数です"},
+		{"変数の再定義", "let x = 1\nlet x = 2\nhold()", "変数 x は既に定義されています"},
+		{"then がない", "if price > 1 hold()", "then が必要です"},
+		{"余分な記述", "sell_pct(1) 2", "余分な記述があります"},
+		{"比較の連鎖", "if 1 < 2 < 3 then hold()", "比較は連ねられません"},
+		{"文字列の計算", "sell_pct(date + 1)", "+ は数値どうしに使ってください"},
+		{"型の異なる比較", "if date == 1 then hold()", "== の両辺の型が異なります"},
+		{"数値の否定", "if not price then hold()", "not は真偽値に使ってください"},
+		{"式の中の動作", "sell_pct(hold())", "動作 hold は式の中では使えません"},
+		{"行番号", "hold()\nsell_pct(foo)", "2行目"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("エラー %q を期待しましたが, %v でした", tt.err, err)
+			}
+		})
+	}
+}
+
+func testEnv() *Env {
+	return &Env{
+		Vars: map[string]any{
+			"price":              100.0,
+			"history":            5.0,
+			"holdings":           1000.0,
+			"date":               "2026-01-05",
+			"sell_day":           true,
+			"sell_days_per_year": 12.0,
+		},
+		Prices: []float64{90, 95, 100, 105, 110},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want Action
+	}{
+		{
+			name: "条件を満たす動作と説明",
+			src:  `if sell_day then sell_pct(50, "売却日")`,
+			want: Action{Name: "sell_pct", Amount: 50, Note: "売却日", Line: 1},
+		},
+		{
+			name: "条件を満たさない規則は飛ばす",
+			src:  "if not sell_day then hold()\nsell_jpy(1000)",
+			want: Action{Name: "sell_jpy", Amount: 1000, Line: 2},
+		},
+		{
+			name: "変数と移動平均",
+			src:  "let ma = sma(5)\nif price < ma then hold(\"下落中\")\nsell_units(10)",
+			want: Action{Name: "sell_units", Amount: 10, Line: 3},
+		},
+		{
+			name: "最初の動作で終える",
+			src:  "if price <= sma(5) then hold(\"下落中\")\nsell_units(10)",
+			want: Action{Name: "hold", Note: "下落中", Line: 1},
+		},
+		{
+			name: "どの動作も実行されない",
+			src:  "if high(3) - low(3) > 10 then sell_units(1)",
+			want: Action{},
+		},
+		{
+			name: "文字列の比較と組み込みの関数",
+			src:  `if date == "2026-01-05" and history >= 5 then sell_units(max(3, 7) % 4 + round(change(4)))`,
+			want: Action{Name: "sell_units", Amount: 3 + 22, Line: 1},
+		},
+		{
+			name: "演算子の優先順位",
+			src:  "sell_pct(4 / sell_days_per_year * 3 - 1 + -(-1))",
+			want: Action{Name: "sell_pct", Amount: 1, Line: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("予期しないエラー: %v", err)
+			}
+			got, err := p.Evaluate(testEnv())
+			if err != nil {
+				t.Fatalf("予期しないエラー: %v", err)
+			}
+			if math.Abs(got.Amount-tt.want.Amount) < 1e-9 {
+				got.Amount = tt.want.Amount
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"0 での割り算", "let a = 1\nsell_pct(price / (history - 5))", "0 で割ることはできません"},
+		{"基準価額の不足", "sell_pct(sma(10))", "基準価額が 5日分しかありません"},
+		{"日数が整数でない", "sell_pct(sma(1.5))", "sma の日数は 1 以上の整数"},
+		{"割合の上限", "sell_pct(150)", "0〜100 で指定してください"},
+		{"負の値", "sell_jpy(-1)", "sell_jpy に負の値は指定できません"},
+		{"値のない変数", "sell_jpy(cash)", "変数 cash の値がありません"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("予期しないエラー: %v", err)
+			}
+			_, err = p.Evaluate(testEnv())
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("エラー %q を期待しましたが, %v でした", tt.err, err)
+			}
+		})
+	}
+}
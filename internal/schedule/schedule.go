@@ -0,0 +1,435 @@
+// internal/schedule/schedule.go
+package schedule
+
+import (
+	"fmt"
+	"kk-invest/internal/calendar"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 休業日にあたる予定日の扱い
+type Roll string
+
+const (
+	RollAuto     Roll = "auto"     // 平日の休業日は翌営業日, 土日はそのまま (注文を出す日として扱う)
+	RollForward  Roll = "forward"  // 翌営業日
+	RollBackward Roll = "backward" // 前営業日
+	RollNone     Roll = "none"     // そのまま
+)
+
+// 売却日などの予定の規則
+//
+// 規則は空白区切りで "種類:値" と任意の修飾子を並べて記述する
+//
+//	weekly:sun            毎週日曜日 (曜日はカンマ区切りで複数指定可)
+//	weekly:fri every=2    隔週金曜日
+//	monthly:1             毎月1日 (last で月末, カンマ区切りで複数指定可)
+//	bizday:1              毎月第1営業日 (負の値は月末から数える. -1 で最終営業日)
+//	dates:2026-01-05,...  指定した日付のみ
+//
+// 修飾子:
+//
+//	roll=auto|forward|backward|none  休業日にあたる場合の扱い (既定は auto)
+//	every=N                          N 週 (monthly, bizday は N か月) ごと
+//	from=YYYY-MM-DD                  every の起点 (既定は 2000-01-02 の週, 2000年1月)
+type Schedule struct {
+	expr   string
+	kind   string
+	days   []int // weekly: 曜日, monthly: 日 (0 は月末), bizday: 何営業日目か
+	dates  map[string]bool
+	last   time.Time // dates の最後の日付
+	roll   Roll
+	every  int
+	anchor time.Time
+
+	// Is の結果 (日付 -> 予定日かどうか). カレンダーが変わった場合は作り直す
+	mu       sync.Mutex
+	isCache  map[int]bool
+	yearDays map[int][]time.Time // 年 -> その年の予定日
+	cacheCal *calendar.Calendar
+}
+
+const dateLayout = "2006-01-02"
+
+var defaultAnchor = time.Date(2000, time.January, 2, 0, 0, 0, 0, time.Local)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// 規則を解析する
+func Parse(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("規則が空です")
+	}
+	s := &Schedule{expr: strings.Join(fields, " "), roll: RollAuto, every: 1, anchor: defaultAnchor}
+
+	kind, value, ok := strings.Cut(fields[0], ":")
+	if !ok || value == "" {
+		return nil, fmt.Errorf("規則は 種類:値 の形式で指定してください: %q", fields[0])
+	}
+	s.kind = kind
+	values := strings.Split(value, ",")
+	for i, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		switch kind {
+		case "weekly":
+			wd, ok := weekdays[v]
+			if !ok {
+				return nil, fmt.Errorf("曜日が不正です: %q", v)
+			}
+			s.days = append(s.days, int(wd))
+			// 曜日は3文字の略称にそろえ, 同じ規則が常に同じ表記になるようにする
+			values[i] = strings.ToLower(wd.String()[:3])
+		case "monthly":
+			if v == "last" {
+				s.days = append(s.days, 0)
+				continue
+			}
+			d, err := strconv.Atoi(v)
+			if err != nil || d < 1 || d > 31 {
+				return nil, fmt.Errorf("日付は 1〜31 または last で指定してください: %q", v)
+			}
+			s.days = append(s.days, d)
+		case "bizday":
+			n, err := strconv.Atoi(v)
+			if err != nil || n == 0 || n < -23 || n > 23 {
+				return nil, fmt.Errorf("営業日は 1〜23 または -1〜-23 で指定してください: %q", v)
+			}
+			s.days = append(s.days, n)
+		case "dates":
+			t, err := time.ParseInLocation(dateLayout, v, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("日付が不正です: %q", v)
+			}
+			if s.dates == nil {
+				s.dates = make(map[string]bool)
+			}
+			s.dates[v] = true
+			if t.After(s.last) {
+				s.last = t
+			}
+		default:
+			return nil, fmt.Errorf("未知の規則です: %q (weekly, monthly, bizday, dates のいずれか)", kind)
+		}
+	}
+
+	if kind == "weekly" {
+		fields[0] = kind + ":" + strings.Join(values, ",")
+		s.expr = strings.Join(fields, " ")
+	}
+
+	for _, f := range fields[1:] {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("修飾子は key=value の形式で指定してください: %q", f)
+		}
+		switch key {
+		case "roll":
+			switch r := Roll(value); r {
+			case RollAuto, RollForward, RollBackward, RollNone:
+				s.roll = r
+			default:
+				return nil, fmt.Errorf("roll は auto, forward, backward, none のいずれかで指定してください: %q", value)
+			}
+		case "every":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 52 {
+				return nil, fmt.Errorf("every は 1〜52 の整数で指定してください: %q", value)
+			}
+			s.every = n
+		case "from":
+			t, err := time.ParseInLocation(dateLayout, value, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("from の日付が不正です: %q", value)
+			}
+			s.anchor = t
+		default:
+			return nil, fmt.Errorf("未知の修飾子です: %q", key)
+		}
+	}
+	if s.kind == "dates" && s.every != 1 {
+		return nil, fmt.Errorf("dates には every を指定できません")
+	}
+	sort.Ints(s.days)
+	return s, nil
+}
+
+// 曜日を指定した毎週の規則
+func Weekly(wd time.Weekday) *Schedule {
+	s, _ := Parse("weekly:" + strings.ToLower(wd.String()[:3]))
+	return s
+}
+
+// 規則の文字列表現
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// 休業日による移動前の予定日かどうか
+func (s *Schedule) matchesRaw(day time.Time, cal *calendar.Calendar, months monthCache) bool {
+	switch s.kind {
+	case "weekly":
+		if !s.containsDay(int(day.Weekday())) {
+			return false
+		}
+		if s.every > 1 {
+			weeks := daysBetween(startOfWeek(s.anchor), startOfWeek(day)) / 7
+			return mod(weeks, s.every) == 0
+		}
+		return true
+	case "monthly":
+		if !s.monthMatches(day) {
+			return false
+		}
+		lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+		for _, d := range s.days {
+			// 月の日数を超える日付はその月の末日とする
+			if (d == 0 || d >= lastDay) && day.Day() == lastDay {
+				return true
+			}
+			if d == day.Day() {
+				return true
+			}
+		}
+		return false
+	case "bizday":
+		if !s.monthMatches(day) {
+			return false
+		}
+		days := months.businessDays(day, cal)
+		for i, d := range days {
+			if d == day.Day() {
+				return s.containsDay(i+1) || s.containsDay(i-len(days))
+			}
+		}
+		return false
+	case "dates":
+		return s.dates[day.Format(dateLayout)]
+	}
+	return false
+}
+
+func (s *Schedule) containsDay(d int) bool {
+	for _, x := range s.days {
+		if x == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schedule) monthMatches(day time.Time) bool {
+	if s.every <= 1 {
+		return true
+	}
+	months := (day.Year()-s.anchor.Year())*12 + int(day.Month()) - int(s.anchor.Month())
+	return mod(months, s.every) == 0
+}
+
+// 月ごとの営業日の一覧 (年*100+月 -> 日の一覧)
+// 同じ月を何度も数えないよう, Next の呼び出しの間だけ保持する
+type monthCache map[int][]int
+
+func (mc monthCache) businessDays(day time.Time, cal *calendar.Calendar) []int {
+	key := day.Year()*100 + int(day.Month())
+	if days, ok := mc[key]; ok {
+		return days
+	}
+	var days []int
+	for d := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location()); d.Month() == day.Month(); d = d.AddDate(0, 0, 1) {
+		if cal.IsBusinessDay(d) {
+			days = append(days, d.Day())
+		}
+	}
+	mc[key] = days
+	return days
+}
+
+// 予定日 day を休業日の扱いに従って移動する
+func (s *Schedule) rollDay(day time.Time, cal *calendar.Calendar) time.Time {
+	if cal.IsBusinessDay(day) {
+		return day
+	}
+	switch s.roll {
+	case RollForward:
+		return cal.NextBusinessDay(day)
+	case RollBackward:
+		return cal.PrevBusinessDay(day)
+	case RollAuto:
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			return cal.NextBusinessDay(day)
+		}
+	}
+	return day
+}
+
+// from 以降 (当日を含む) で最初の予定日
+// 休業日による移動の結果 from 以降になる予定日も含む. 以降の予定がない場合は false を返す
+func (s *Schedule) Next(from time.Time, cal *calendar.Calendar) (time.Time, bool) {
+	from = truncate(from)
+	// 休業日による移動は長くても年末年始と連休が重なる程度なので, 前後数週間を探せば足りる
+	start := from.AddDate(0, 0, -21)
+	end := from.AddDate(0, s.every+1, 0).AddDate(0, 0, 21)
+	if s.kind == "weekly" {
+		end = from.AddDate(0, 0, 7*s.every+21)
+	}
+	if s.kind == "dates" {
+		end = s.last.AddDate(0, 0, 21)
+	}
+	months := make(monthCache)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if !s.matchesRaw(day, cal, months) {
+			continue
+		}
+		if rolled := s.rollDay(day, cal); !rolled.Before(from) {
+			return rolled, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// day が予定日かどうか
+func (s *Schedule) Is(day time.Time, cal *calendar.Calendar) bool {
+	y, m, d := day.Date()
+	key := y*10000 + int(m)*100 + d
+
+	s.mu.Lock()
+	s.resetCache(cal)
+	is, ok := s.isCache[key]
+	s.mu.Unlock()
+	if ok {
+		return is
+	}
+
+	next, found := s.Next(day, cal)
+	is = found && next.Equal(truncate(day))
+
+	s.mu.Lock()
+	s.isCache[key] = is
+	s.mu.Unlock()
+	return is
+}
+
+// year 年の予定日の数
+// 年率で指定された金額や割合を, 売却日ごとに分割する際に使う
+func (s *Schedule) CountInYear(year int, cal *calendar.Calendar) int {
+	return len(s.inYear(year, cal))
+}
+
+// year 年の予定日の一覧 (古い順)
+func (s *Schedule) inYear(year int, cal *calendar.Calendar) []time.Time {
+	s.mu.Lock()
+	s.resetCache(cal)
+	days, ok := s.yearDays[year]
+	s.mu.Unlock()
+	if ok {
+		return days
+	}
+
+	day := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	for {
+		next, found := s.Next(day, cal)
+		if !found || next.Year() != year {
+			break
+		}
+		days = append(days, next)
+		day = next.AddDate(0, 0, 1)
+	}
+
+	s.mu.Lock()
+	s.yearDays[year] = days
+	s.mu.Unlock()
+	return days
+}
+
+// from から to まで (両端を含む) の予定日の数
+func (s *Schedule) CountBetween(from, to time.Time, cal *calendar.Calendar) int {
+	from, to = truncate(from), truncate(to)
+	n := 0
+	day := from
+	// 年の途中は1日ずつ, 年をまたぐ部分は年ごとの数を使う
+	for ; !day.After(to) && (day.Month() != time.January || day.Day() != 1 || day.Year() == to.Year()); day = day.AddDate(0, 0, 1) {
+		if s.Is(day, cal) {
+			n++
+		}
+	}
+	for ; !day.After(to) && day.Year() < to.Year(); day = day.AddDate(1, 0, 0) {
+		n += s.CountInYear(day.Year(), cal)
+	}
+	for ; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if s.Is(day, cal) {
+			n++
+		}
+	}
+	return n
+}
+
+// カレンダーが変わった場合は覚えている結果を捨てる (s.mu を取得した状態で呼び出す)
+func (s *Schedule) resetCache(cal *calendar.Calendar) {
+	if s.cacheCal != cal || s.isCache == nil {
+		s.isCache = make(map[int]bool)
+		s.yearDays = make(map[int][]time.Time)
+		s.cacheCal = cal
+	}
+}
+
+// before より前 (当日を含まない) で since 以降の予定日を, 新しいものから最大 n 件返す
+func (s *Schedule) Previous(before, since time.Time, n int, cal *calendar.Calendar) []time.Time {
+	before, since = truncate(before), truncate(since)
+	var out []time.Time
+	for year := before.Year(); len(out) < n && year >= since.Year(); year-- {
+		days := s.inYear(year, cal)
+		for i := len(days) - 1; i >= 0 && len(out) < n; i-- {
+			if days[i].Before(since) {
+				return out
+			}
+			if days[i].Before(before) {
+				out = append(out, days[i])
+			}
+		}
+	}
+	return out
+}
+
+// from 以降の予定日を最大 n 件返す
+func (s *Schedule) Upcoming(from time.Time, n int, cal *calendar.Calendar) []time.Time {
+	var out []time.Time
+	for day := from; len(out) < n; {
+		next, ok := s.Next(day, cal)
+		if !ok {
+			break
+		}
+		out = append(out, next)
+		day = next.AddDate(0, 0, 1)
+	}
+	return out
+}
+
+func truncate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func startOfWeek(t time.Time) time.Time {
+	t = truncate(t)
+	return t.AddDate(0, 0, -int(t.Weekday()))
+}
+
+func daysBetween(a, b time.Time) int {
+	// 夏時間のある地域でも日数がずれないよう, 暦の日付を UTC で比較する
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
@@ -0,0 +1,138 @@
+// internal/schedule/schedule_test.go
+package schedule
+
+import (
+	"kk-invest/internal/calendar"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.ParseInLocation(dateLayout, s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // String() の結果
+		err  string
+	}{
+		{expr: "weekly:sun", want: "weekly:sun"},
+		{expr: "weekly:Sunday,FRI", want: "weekly:sun,fri"},
+		{expr: "  bizday:1   roll=forward ", want: "bizday:1 roll=forward"},
+		{expr: "monthly:1,last every=3 from=2026-01-01", want: "monthly:1,last every=3 from=2026-01-01"},
+		{expr: "dates:2026-01-05,2026-02-02", want: "dates:2026-01-05,2026-02-02"},
+		{expr: "", err: "規則が空"},
+		{expr: "weekly", err: "種類:値"},
+		{expr: "weekly:xyz", err: "曜日が不正"},
+		{expr: "monthly:32", err: "1〜31"},
+		{expr: "bizday:0", err: "営業日は"},
+		{expr: "bizday:24", err: "営業日は"},
+		{expr: "daily:1", err: "未知の規則"},
+		{expr: "dates:2026/01/05", err: "日付が不正"},
+		{expr: "monthly:1 roll=sideways", err: "roll は"},
+		{expr: "weekly:sun every=0", err: "every は"},
+		{expr: "weekly:sun from=2026/01/01", err: "from の日付"},
+		{expr: "weekly:sun foo", err: "key=value"},
+		{expr: "weekly:sun foo=1", err: "未知の修飾子"},
+		{expr: "dates:2026-01-05 every=2", err: "every を指定できません"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("エラー %q を期待しましたが, %v でした", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("予期しないエラー: %v", err)
+			}
+			if got := s.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIs(t *testing.T) {
+	// 2026-01-01 (木) は元日, 01-02, 01-03 は年末年始, 01-12 (月) は成人の日, 02-11 (水) は建国記念の日
+	tests := []struct {
+		expr string
+		day  string
+		want bool
+	}{
+		{"weekly:sun", "2026-01-04", true},
+		{"weekly:sun", "2026-01-05", false},
+		{"weekly:mon", "2026-01-12", false}, // 祝日の月曜日は翌営業日に繰り下げる
+		{"weekly:mon", "2026-01-13", true},
+		{"weekly:fri every=2 from=2026-01-09", "2026-01-09", true},
+		{"weekly:fri every=2 from=2026-01-09", "2026-01-16", false},
+		{"weekly:fri every=2 from=2026-01-09", "2026-01-23", true},
+		{"monthly:1", "2026-02-01", true}, // 日曜日は auto では移動しない
+		{"monthly:1 roll=forward", "2026-02-01", false},
+		{"monthly:1 roll=forward", "2026-02-02", true},
+		{"monthly:11", "2026-02-11", false},
+		{"monthly:11", "2026-02-12", true},
+		{"monthly:11 roll=backward", "2026-02-10", true},
+		{"monthly:11 roll=none", "2026-02-11", true},
+		{"monthly:31", "2026-02-28", true}, // 月の日数を超える日付は月末
+		{"monthly:last", "2026-04-30", true},
+		{"monthly:1 every=2 from=2026-01-01", "2026-03-01", true},
+		{"monthly:1 every=2 from=2026-01-01", "2026-04-01", false},
+		{"bizday:1", "2026-01-02", false},
+		{"bizday:1", "2026-01-05", true},
+		{"bizday:2", "2026-01-06", true},
+		{"bizday:-1", "2026-01-30", true},
+		{"bizday:-1", "2026-01-31", false},
+		{"dates:2026-03-02", "2026-03-02", true},
+		{"dates:2026-03-02", "2026-03-03", false},
+	}
+	cal := calendar.Embedded()
+	for _, tt := range tests {
+		t.Run(tt.expr+" "+tt.day, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("予期しないエラー: %v", err)
+			}
+			// 2回目はキャッシュから返るので, 同じ結果になることも確かめる
+			for i := 0; i < 2; i++ {
+				if got := s.Is(date(tt.day), cal); got != tt.want {
+					t.Errorf("%d回目: got %v, want %v", i+1, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCountInYear(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int
+	}{
+		{"weekly:sun", 52},
+		{"weekly:sun,wed", 104},
+		{"weekly:fri every=2 from=2026-01-09", 26},
+		{"monthly:1", 12},
+		{"bizday:1", 12},
+		{"dates:2026-03-02,2027-03-01", 1},
+	}
+	cal := calendar.Embedded()
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("予期しないエラー: %v", err)
+			}
+			if got := s.CountInYear(2026, cal); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
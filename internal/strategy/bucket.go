@@ -0,0 +1,210 @@
+// internal/strategy/bucket.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/schedule"
+	"math"
+	"strings"
+)
+
+// 生活費の現金バケツ
+//
+// 生活費の TargetMonths か月分を現金で持ち, 現金残高が RefillMonths か月分を下回った売却日にだけ, TargetMonths か月分まで補充する
+// 基準価額が直近 PeakWindow 日の最高値から MaxDrawdown を超えて下落している間は補充を見送る
+// ただし現金残高が EmergencyMonths か月分を下回った場合は, 下落中でも RefillMonths か月分までは補充する
+//
+// 現金残高は取引履歴から計算する (売却代金と分配金で増え, 手数料と引き出しで減る) ため, 生活費は add withdrawal で記録する
+type BucketStrategy struct {
+	MonthlyExpense  float64            // 1か月の生活費 (円)
+	TargetMonths    float64            // 補充後の現金残高 (生活費の何か月分か)
+	RefillMonths    float64            // 現金残高がこれを下回ったら補充する
+	EmergencyMonths float64            // 現金残高がこれを下回ったら下落中でも補充する
+	MaxDrawdown     float64            // 最高値からの下落率がこれを超えている間は補充を見送る
+	PeakWindow      int                // 最高値を探す期間 (記録された基準価額の日数)
+	InitialCash     float64            // 取引履歴に記録されていない現金残高 (円)
+	Schedule        *schedule.Schedule // 売却日の規則
+	MinOrderJPY     float64            // 売却代金の見込みがこの金額に満たない場合は売却しない
+}
+
+func init() {
+	Register("bucket", "生活費の数か月分を現金で持ち, 現金残高が減った時だけ売却して補充する. 基準価額が最高値から大きく下落している間は補充を見送る (現金バケツ)", func() Strategy {
+		return NewBucketStrategy()
+	})
+}
+
+// 既定の引数で BucketStrategy を生成
+func NewBucketStrategy() *BucketStrategy {
+	sched, _ := schedule.Parse("bizday:1")
+	return &BucketStrategy{
+		MonthlyExpense:  200000,
+		TargetMonths:    12,
+		RefillMonths:    6,
+		EmergencyMonths: 2,
+		MaxDrawdown:     0.15,
+		PeakWindow:      250,
+		Schedule:        sched,
+		MinOrderJPY:     1000,
+	}
+}
+
+func (s *BucketStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "monthly_expense", Type: ParamFloat, Default: "200000", Min: bound(1),
+			Description: "1か月の生活費 (円)"},
+		{Name: "target_months", Type: ParamFloat, Default: "12", Min: bound(0.1), Max: bound(120),
+			Description: "補充後の現金残高 (生活費の何か月分か)"},
+		{Name: "refill_months", Type: ParamFloat, Default: "6", Min: bound(0), Max: bound(120),
+			Description: "現金残高が生活費のこの月数分を下回ったら補充する"},
+		{Name: "emergency_months", Type: ParamFloat, Default: "2", Min: bound(0), Max: bound(120),
+			Description: "現金残高が生活費のこの月数分を下回ったら, 下落中でも refill_months まで補充する"},
+		{Name: "max_drawdown", Type: ParamFloat, Default: "0.15", Min: bound(0), Max: bound(1),
+			Description: "基準価額の最高値からの下落率がこれを超えている間は補充を見送る (1 で常に補充する)"},
+		{Name: "peak_window", Type: ParamInt, Default: "250", Min: bound(1), Max: bound(5000),
+			Description: "最高値を探す期間 (記録された基準価額の日数)"},
+		{Name: "initial_cash", Type: ParamFloat, Default: "0",
+			Description: "取引履歴に記録されていない現金残高 (円). 取引履歴から計算した残高に加える"},
+		scheduleParam("bizday:1", ""),
+		{Name: "min_order_jpy", Type: ParamFloat, Default: "1000", Min: bound(0),
+			Description: "最小注文金額 (円). 売却代金の見込みがこれに満たない場合は売却しない"},
+	}
+}
+
+func (s *BucketStrategy) Configure(values ParamValues) error {
+	sched, err := schedule.Parse(values.String("schedule"))
+	if err != nil {
+		return fmt.Errorf("売却日の規則: %w", err)
+	}
+	s.Schedule = sched
+	s.MonthlyExpense = values.Float("monthly_expense")
+	s.TargetMonths = values.Float("target_months")
+	s.RefillMonths = values.Float("refill_months")
+	s.EmergencyMonths = values.Float("emergency_months")
+	s.MaxDrawdown = values.Float("max_drawdown")
+	s.PeakWindow = values.Int("peak_window")
+	s.InitialCash = values.Float("initial_cash")
+	s.MinOrderJPY = values.Float("min_order_jpy")
+
+	if s.RefillMonths > s.TargetMonths {
+		return fmt.Errorf("refill_months (%g) は target_months (%g) 以下で指定してください", s.RefillMonths, s.TargetMonths)
+	}
+	if s.EmergencyMonths > s.RefillMonths {
+		return fmt.Errorf("emergency_months (%g) は refill_months (%g) 以下で指定してください", s.EmergencyMonths, s.RefillMonths)
+	}
+	return nil
+}
+
+func (s *BucketStrategy) SellSchedule() *schedule.Schedule {
+	return s.Schedule
+}
+
+// 現金残高
+func (s *BucketStrategy) cash(input AnalysisInput) float64 {
+	return float64(input.Portfolio.Cash) + s.InitialCash
+}
+
+// 直近 PeakWindow 日の最高値と, そこからの下落率
+func (s *BucketStrategy) drawdown(input AnalysisInput) (peak, dd float64) {
+	prices := input.HistoricalPrices
+	if len(prices) == 0 {
+		return 0, 0
+	}
+	for _, p := range prices[max(len(prices)-s.PeakWindow, 0):] {
+		peak = math.Max(peak, float64(p.Price))
+	}
+	if peak <= 0 {
+		return 0, 0
+	}
+	return peak, 1 - float64(prices[len(prices)-1].Price)/peak
+}
+
+// 補充の目標額 (現金残高をこの金額まで増やす) と, その理由
+// 補充しない場合は 0 を返す
+func (s *BucketStrategy) refillTarget(input AnalysisInput) (float64, string) {
+	cash := s.cash(input)
+	months := cash / s.MonthlyExpense
+	_, dd := s.drawdown(input)
+	state := fmt.Sprintf("現金残高 %.0f 円 (生活費の %.1fか月分), 最高値からの下落率 %.2f%%", cash, months, dd*100)
+
+	switch {
+	case months >= s.RefillMonths:
+		return 0, fmt.Sprintf("%s. 補充の基準 (%gか月分) を下回っていないため, 売却しません", state, s.RefillMonths)
+	case dd <= s.MaxDrawdown:
+		return s.TargetMonths * s.MonthlyExpense, fmt.Sprintf("%s. %gか月分まで補充します", state, s.TargetMonths)
+	case months < s.EmergencyMonths:
+		return s.RefillMonths * s.MonthlyExpense, fmt.Sprintf("%s. 下落中ですが, 現金残高が %gか月分を下回ったため %gか月分まで補充します", state, s.EmergencyMonths, s.RefillMonths)
+	default:
+		return 0, fmt.Sprintf("%s. 下落率が %.2f%% を超えているため, 補充を見送ります", state, s.MaxDrawdown*100)
+	}
+}
+
+// 売却日に売却する口数と金額の見込み
+// 補充の目標額に足りない金額を最新の基準価額で口数に換算し, 不足しないよう切り上げる
+func (s *BucketStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	target, _ := s.refillTarget(input)
+	amount := target - s.cash(input)
+	if amount <= 0 {
+		return 0, 0
+	}
+	units = min(int(math.Ceil(amount/unitPrice)), input.Portfolio.TotalUnits)
+	return units, float64(units) * unitPrice
+}
+
+func (s *BucketStrategy) Decide(input AnalysisInput) SellDecision {
+	units, jpy := s.Target(input)
+
+	if !s.Schedule.Is(input.Date, input.BusinessCalendar()) {
+		reason, next, ok := notSellDayReason(input, s.Schedule)
+		if ok && units > 0 {
+			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円, 現在の現金残高と基準価額の場合)", next.Format("2006-01-02"), units, jpy)
+		}
+		return SellDecision{Reason: reason}
+	}
+
+	if _, ok := input.LatestUnitPrice(); !ok {
+		return SellDecision{Reason: "過去の価格データがありません"}
+	}
+	if input.Portfolio.TotalUnits <= 0 {
+		return SellDecision{Reason: "保有口数がありません"}
+	}
+
+	_, why := s.refillTarget(input)
+	reason := fmt.Sprintf("%s は売却日です. %s", input.Date.Format("2006-01-02"), why)
+	if units <= 0 {
+		return SellDecision{Reason: reason}
+	}
+	if jpy < s.MinOrderJPY {
+		return SellDecision{
+			Reason: fmt.Sprintf("%s\n売却代金の見込み (%.0f 円) が最小注文金額 (%.0f 円) に満たないため, 売却しません", reason, jpy, s.MinOrderJPY),
+		}
+	}
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      units,
+		ExpectedProceeds: jpy,
+		Reason:           fmt.Sprintf("%s\n%d口 (%.0f 円) を売却します", reason, units, jpy),
+	}
+}
+
+// 現金残高と補充の基準, 下落率
+func (s *BucketStrategy) Status(input AnalysisInput) string {
+	var b strings.Builder
+	cash := s.cash(input)
+	fmt.Fprintf(&b, "現金残高: %.0f 円 (生活費 %.0f 円の %.1fか月分)\n", cash, s.MonthlyExpense, cash/s.MonthlyExpense)
+	if s.InitialCash != 0 {
+		fmt.Fprintf(&b, "  うち取引履歴にない現金: %.0f 円\n", s.InitialCash)
+	}
+	fmt.Fprintf(&b, "補充の基準: %gか月分 (%.0f 円) を下回ったら %gか月分 (%.0f 円) まで\n",
+		s.RefillMonths, s.RefillMonths*s.MonthlyExpense, s.TargetMonths, s.TargetMonths*s.MonthlyExpense)
+	fmt.Fprintf(&b, "緊急時の基準: %gか月分 (%.0f 円)\n", s.EmergencyMonths, s.EmergencyMonths*s.MonthlyExpense)
+	if peak, dd := s.drawdown(input); peak > 0 {
+		fmt.Fprintf(&b, "直近 %d日の最高値: %.0f (下落率: %.2f%%, 見送りの基準: %.2f%%)\n", s.PeakWindow, peak, dd*100, s.MaxDrawdown*100)
+	}
+	_, why := s.refillTarget(input)
+	fmt.Fprintf(&b, "判断: %s\n", why)
+	return b.String()
+}
@@ -0,0 +1,325 @@
+// internal/strategy/composite.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/data"
+	"kk-invest/internal/schedule"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 戦略の組み合わせ方
+var Combines = []string{"min", "max", "and", "or", "sequence"}
+
+// 組み合わせ方の説明
+var combineDescriptions = map[string]string{
+	"min":      "全ての戦略が売却と判断した場合に, 最も少ない口数を売却する",
+	"max":      "売却と判断した戦略のうち, 最も多い口数を売却する",
+	"and":      "全ての戦略が売却と判断した場合に, 1番目の戦略の口数を売却する (2番目以降は条件として使う)",
+	"or":       "売却と判断した戦略のうち, 最も前にある戦略の口数を売却する",
+	"sequence": "前の戦略の売却を反映した保有口数で次の戦略が判断し, 全ての売却口数を合計する",
+}
+
+// 組み合わせる戦略と, その引数
+type CompositeChild struct {
+	Name   string
+	Params map[string]string
+}
+
+// 組み合わせた戦略の定義
+type CompositeDef struct {
+	Description string
+	Combine     string
+	Children    []CompositeChild
+}
+
+// 設定ファイルで定義された, 複数の戦略を組み合わせた戦略
+type CompositeStrategy struct {
+	Def      CompositeDef
+	children []Strategy
+}
+
+// 組み合わせた戦略をまとめて登録する
+// 組み合わせ方と子の戦略の名前を確かめ, 互いに参照し合う定義 (子の戦略や引数 inner による循環) はエラーとする
+func RegisterComposites(defs map[string]CompositeDef) error {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		def := defs[name]
+		if _, exists := registry[name]; exists {
+			return fmt.Errorf("%s: 同じ名前の戦略が既に登録されています", name)
+		}
+		if combineDescriptions[def.Combine] == "" {
+			return fmt.Errorf("%s: combine は %s のいずれかで指定してください: %q", name, strings.Join(Combines, ", "), def.Combine)
+		}
+		if len(def.Children) == 0 {
+			return fmt.Errorf("%s: children を指定してください", name)
+		}
+	}
+	for _, name := range names {
+		for i, child := range defs[name].Children {
+			if _, ok := registry[child.Name]; !ok && defs[child.Name].Combine == "" {
+				return fmt.Errorf("%s: %d番目の戦略 %q は登録されていません", name, i+1, child.Name)
+			}
+		}
+	}
+
+	// 組み合わせた戦略どうしの参照をたどって循環を探す
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		def, ok := defs[name]
+		if !ok {
+			return nil
+		}
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("組み合わせた戦略が循環しています: %s", strings.Join(append(path, name), " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, child := range def.Children {
+			if err := visit(child.Name, append(path, name)); err != nil {
+				return err
+			}
+			if inner := child.Params["inner"]; inner != "" {
+				if err := visit(inner, append(path, name, child.Name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		def := defs[name]
+		description := def.Description
+		if description == "" {
+			var children []string
+			for _, c := range def.Children {
+				children = append(children, c.Name)
+			}
+			description = fmt.Sprintf("%s を %s で組み合わせた戦略", strings.Join(children, ", "), def.Combine)
+		}
+		Register(name, description, func() Strategy {
+			return &CompositeStrategy{Def: def}
+		})
+	}
+	return nil
+}
+
+// 組み合わせた戦略の引数は子の戦略ごとに設定ファイルで指定するため, 引数はない
+func (s *CompositeStrategy) Params() []ParamSpec {
+	return nil
+}
+
+// 子の戦略を生成する
+func (s *CompositeStrategy) Configure(values ParamValues) error {
+	s.children = nil
+	for i, child := range s.Def.Children {
+		c, _, err := Build(child.Name, child.Params)
+		if err != nil {
+			return fmt.Errorf("%d番目の戦略 %s: %w", i+1, child.Name, err)
+		}
+		s.children = append(s.children, c)
+	}
+	return nil
+}
+
+// 全ての子の戦略が同じ売却日の規則を持つ場合はその規則, それ以外は nil
+func (s *CompositeStrategy) SellSchedule() *schedule.Schedule {
+	var common *schedule.Schedule
+	for _, c := range s.children {
+		sched, ok := SellScheduleOf(c)
+		if !ok || (common != nil && sched.String() != common.String()) {
+			return nil
+		}
+		common = sched
+	}
+	return common
+}
+
+// 子の戦略の売却を反映した入力 (sequence で次の戦略に渡す)
+func afterSell(input AnalysisInput, units int) AnalysisInput {
+	unitPrice, ok := input.LatestUnitPrice()
+	if units <= 0 || !ok {
+		return input
+	}
+	units = min(units, input.Portfolio.TotalUnits)
+	proceeds := int(float64(units) * unitPrice)
+	next := input
+	next.Transactions = append(append([]data.Transaction(nil), input.Transactions...), data.Transaction{
+		Datetime:  input.Date.Format(time.RFC3339),
+		Type:      "sell",
+		AmountJPY: proceeds,
+		Units:     units,
+	})
+	portfolio := *input.Portfolio
+	portfolio.ApplySell(proceeds, units)
+	if len(input.HistoricalPrices) > 0 {
+		portfolio.Valuate(input.HistoricalPrices[len(input.HistoricalPrices)-1].Price)
+	}
+	next.Portfolio = &portfolio
+	return next
+}
+
+// 子の戦略の売却口数を組み合わせ方に従ってまとめ, その説明を返す
+// units[i] は i 番目の戦略の売却口数 (売却しない場合は 0)
+func (s *CompositeStrategy) combine(units []int) (int, string) {
+	names := make([]string, len(units))
+	var selling, declined []string
+	for i, u := range units {
+		names[i] = fmt.Sprintf("[%d] %s", i+1, s.Def.Children[i].Name)
+		if u > 0 {
+			selling = append(selling, names[i])
+		} else {
+			declined = append(declined, names[i])
+		}
+	}
+
+	switch s.Def.Combine {
+	case "min", "and":
+		if len(declined) > 0 {
+			return 0, fmt.Sprintf("%s が売却しないと判断したため, 売却しません", strings.Join(declined, ", "))
+		}
+		if s.Def.Combine == "and" {
+			return units[0], fmt.Sprintf("全ての戦略が売却と判断したため, %s の口数を売却します", names[0])
+		}
+		least := 0
+		for i, u := range units {
+			if u < units[least] {
+				least = i
+			}
+		}
+		return units[least], fmt.Sprintf("全ての戦略が売却と判断したため, 最も少ない %s の口数を売却します", names[least])
+	case "max":
+		most := -1
+		for i, u := range units {
+			if u > 0 && (most < 0 || u > units[most]) {
+				most = i
+			}
+		}
+		if most < 0 {
+			return 0, "どの戦略も売却と判断しなかったため, 売却しません"
+		}
+		return units[most], fmt.Sprintf("最も多い %s の口数を売却します", names[most])
+	case "or":
+		for i, u := range units {
+			if u > 0 {
+				return u, fmt.Sprintf("最初に売却と判断した %s の口数を売却します", names[i])
+			}
+		}
+		return 0, "どの戦略も売却と判断しなかったため, 売却しません"
+	default: // sequence
+		total := 0
+		for _, u := range units {
+			total += u
+		}
+		if total == 0 {
+			return 0, "どの戦略も売却と判断しなかったため, 売却しません"
+		}
+		return total, fmt.Sprintf("%s の売却口数を合計して売却します", strings.Join(selling, ", "))
+	}
+}
+
+// 売却日に売却する口数と金額の見込み (見込みを計算できる子の戦略だけを組み合わせる)
+func (s *CompositeStrategy) Target(input AnalysisInput) (int, float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	var units []int
+	var kept []CompositeChild
+	current := input
+	for i, c := range s.children {
+		t, ok := c.(Targeted)
+		if !ok {
+			continue
+		}
+		u, _ := t.Target(current)
+		units = append(units, u)
+		kept = append(kept, s.Def.Children[i])
+		if s.Def.Combine == "sequence" {
+			current = afterSell(current, u)
+		}
+	}
+	if len(units) == 0 {
+		return 0, 0
+	}
+	partial := &CompositeStrategy{Def: CompositeDef{Combine: s.Def.Combine, Children: kept}}
+	total, _ := partial.combine(units)
+	total = min(total, input.Portfolio.TotalUnits)
+	return total, float64(total) * unitPrice
+}
+
+func (s *CompositeStrategy) Decide(input AnalysisInput) SellDecision {
+	units := make([]int, len(s.children))
+	var b strings.Builder
+	current := input
+	for i, c := range s.children {
+		d := c.Decide(current)
+		if d.ShouldSell {
+			units[i] = d.UnitsToSell
+		}
+
+		fmt.Fprintf(&b, "\n[%d] %s: ", i+1, s.Def.Children[i].Name)
+		if units[i] > 0 {
+			fmt.Fprintf(&b, "売却 %d口 (%.0f 円)", units[i], d.ExpectedProceeds)
+		} else {
+			b.WriteString("売却しない")
+		}
+		for _, line := range strings.Split(d.Reason, "\n") {
+			b.WriteString("\n    " + line)
+		}
+
+		if s.Def.Combine == "sequence" {
+			current = afterSell(current, units[i])
+		}
+	}
+
+	total, summary := s.combine(units)
+	total = min(total, input.Portfolio.TotalUnits)
+	reason := fmt.Sprintf("組み合わせ (%s): %s%s", s.Def.Combine, summary, b.String())
+	if total <= 0 {
+		return SellDecision{Reason: reason}
+	}
+	unitPrice, _ := input.LatestUnitPrice()
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      total,
+		ExpectedProceeds: float64(total) * unitPrice,
+		Reason:           reason,
+	}
+}
+
+// 子の戦略の状態
+func (s *CompositeStrategy) Status(input AnalysisInput) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "組み合わせ: %s (%s)\n", s.Def.Combine, combineDescriptions[s.Def.Combine])
+	for i, c := range s.children {
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, s.Def.Children[i].Name)
+		if r, ok := c.(Reporter); ok {
+			for _, line := range strings.Split(strings.TrimRight(r.Status(input), "\n"), "\n") {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+// internal/strategy/cpi.go
+package strategy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 消費者物価指数の系列
+// 月次 ("YYYY-MM,指数") または年次 ("YYYY,指数") の CSV から読み込む. 年次の値はその年の1月から適用する
+type CPISeries struct {
+	points []cpiPoint
+}
+
+type cpiPoint struct {
+	month int // 年*12 + (月-1)
+	value float64
+}
+
+// CSV ファイルから物価指数を読み込む
+// 空行, # で始まる行, 数値で始まらない行 (見出し) は無視する
+func LoadCPI(path string) (*CPISeries, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("物価指数のファイルを開けません: %w", err)
+	}
+	defer f.Close()
+
+	var series CPISeries
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line[0] < '0' || line[0] > '9' {
+			continue
+		}
+		period, valueStr, ok := strings.Cut(line, ",")
+		if !ok {
+			return nil, fmt.Errorf("%s %d行目: 期間,指数 の形式で記述してください", path, lineNo)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(valueStr), 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("%s %d行目: 指数が不正です: %q", path, lineNo, valueStr)
+		}
+
+		var t time.Time
+		period = strings.TrimSpace(period)
+		if len(period) == 4 {
+			t, err = time.Parse("2006", period)
+		} else {
+			t, err = time.Parse("2006-01", period)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %d行目: 期間は YYYY-MM または YYYY で指定してください: %q", path, lineNo, period)
+		}
+		series.points = append(series.points, cpiPoint{month: monthIndex(t), value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(series.points) == 0 {
+		return nil, fmt.Errorf("%s に物価指数がありません", path)
+	}
+	sort.Slice(series.points, func(i, j int) bool { return series.points[i].month < series.points[j].month })
+	return &series, nil
+}
+
+// t の時点で公表されている最新の指数 (t より前の値がない場合は最初の値)
+func (c *CPISeries) At(t time.Time) float64 {
+	m := monthIndex(t)
+	i := sort.Search(len(c.points), func(i int) bool { return c.points[i].month > m })
+	if i == 0 {
+		return c.points[0].value
+	}
+	return c.points[i-1].value
+}
+
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
@@ -0,0 +1,178 @@
+// internal/strategy/fixed_amount.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/schedule"
+	"math"
+	"time"
+)
+
+// 定額取り崩し
+// 売却日ごとに AmountJPY に相当する口数を売却する. 金額は毎年 InflationRate, または物価指数の変化に合わせて引き上げる
+type FixedAmountStrategy struct {
+	AmountJPY     float64            // 1回の売却日に受け取る金額 (基準日時点)
+	Schedule      *schedule.Schedule // 売却日の規則
+	InflationRate float64            // 金額を毎年引き上げる割合
+	CPI           *CPISeries         // 物価指数 (指定した場合は InflationRate の代わりに使う)
+	BaseDate      time.Time          // 金額の基準日 (物価調整する場合は必須)
+	WarnPeriods   int                // 残りの保有資産がこの回数分を下回ったら警告する
+}
+
+func init() {
+	Register("fixed_amount", "売却日ごとに一定の金額に相当する口数を売却する. 金額は物価上昇率や物価指数に合わせて毎年引き上げられる (定額取り崩し)", func() Strategy {
+		return NewFixedAmountStrategy()
+	})
+}
+
+// 既定の引数で FixedAmountStrategy を生成
+func NewFixedAmountStrategy() *FixedAmountStrategy {
+	sched, _ := schedule.Parse("bizday:1")
+	return &FixedAmountStrategy{
+		AmountJPY:   100000,
+		Schedule:    sched,
+		WarnPeriods: 12,
+	}
+}
+
+func (s *FixedAmountStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "amount_jpy", Type: ParamFloat, Default: "100000", Min: bound(1),
+			Description: "1回の売却日に受け取る金額 (円, 基準日時点)"},
+		scheduleParam("bizday:1", ""),
+		{Name: "inflation_rate", Type: ParamFloat, Default: "0", Min: bound(-0.5), Max: bound(1),
+			Description: "金額を基準日から1年ごとに引き上げる割合 (例: 0.02)"},
+		{Name: "cpi_file", Type: ParamString, Default: "",
+			Description: "物価指数の CSV ファイル (YYYY-MM,指数 または YYYY,指数). 指定した場合は inflation_rate の代わりに基準日からの指数の変化で金額を調整する"},
+		{Name: "base_date", Type: ParamString, Default: "",
+			Description: "金額の基準日 (YYYY-MM-DD). 物価調整はこの日からの変化で行う. inflation_rate や cpi_file を指定する場合は必須"},
+		{Name: "warn_periods", Type: ParamInt, Default: "12", Min: bound(0),
+			Description: "残りの保有資産が現在の金額でこの回数分を下回ったら警告する (0 で警告しない)"},
+	}
+}
+
+func (s *FixedAmountStrategy) Configure(values ParamValues) error {
+	sched, err := schedule.Parse(values.String("schedule"))
+	if err != nil {
+		return fmt.Errorf("売却日の規則: %w", err)
+	}
+	s.Schedule = sched
+	s.AmountJPY = values.Float("amount_jpy")
+	s.InflationRate = values.Float("inflation_rate")
+	s.WarnPeriods = values.Int("warn_periods")
+
+	s.CPI = nil
+	if path := values.String("cpi_file"); path != "" {
+		if s.CPI, err = LoadCPI(path); err != nil {
+			return err
+		}
+	}
+	s.BaseDate = time.Time{}
+	if date := values.String("base_date"); date != "" {
+		if s.BaseDate, err = time.ParseInLocation("2006-01-02", date, time.Local); err != nil {
+			return fmt.Errorf("base_date の日付が不正です: %q", date)
+		}
+	}
+	if s.BaseDate.IsZero() && (s.InflationRate != 0 || s.CPI != nil) {
+		return fmt.Errorf("inflation_rate や cpi_file で物価調整する場合は, base_date に金額の基準日を指定してください")
+	}
+	return nil
+}
+
+func (s *FixedAmountStrategy) SellSchedule() *schedule.Schedule {
+	return s.Schedule
+}
+
+// 金額の基準日 (物価調整しない場合は判断の基準日)
+func (s *FixedAmountStrategy) baseDate(input AnalysisInput) time.Time {
+	if !s.BaseDate.IsZero() {
+		return s.BaseDate
+	}
+	return input.Date
+}
+
+// 物価調整の基準の説明 (物価調整しない場合は空文字列)
+func (s *FixedAmountStrategy) baseNote(input AnalysisInput) string {
+	if s.InflationRate == 0 && s.CPI == nil {
+		return ""
+	}
+	return fmt.Sprintf("\n金額の基準日: %s (%.0f 円)", s.baseDate(input).Format("2006-01-02"), s.AmountJPY)
+}
+
+// 基準日時点の金額に対する, input.Date 時点の金額の倍率
+func (s *FixedAmountStrategy) indexFactor(input AnalysisInput) float64 {
+	base := s.baseDate(input)
+	if s.CPI != nil {
+		return s.CPI.At(input.Date) / s.CPI.At(base)
+	}
+	if s.InflationRate == 0 {
+		return 1
+	}
+	years := input.Date.Year() - base.Year()
+	if input.Date.YearDay() < base.YearDay() {
+		years--
+	}
+	return math.Pow(1+s.InflationRate, float64(max(years, 0)))
+}
+
+// 売却日に売却する口数と金額の見込み
+// 物価調整後の金額を最新の基準価額で口数に換算し, 金額を下回らないよう切り上げる
+func (s *FixedAmountStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	amount := s.AmountJPY * s.indexFactor(input)
+	units = min(int(math.Ceil(amount/unitPrice)), input.Portfolio.TotalUnits)
+	return units, float64(units) * unitPrice
+}
+
+func (s *FixedAmountStrategy) Decide(input AnalysisInput) SellDecision {
+	units, jpy := s.Target(input)
+
+	if !s.Schedule.Is(input.Date, input.BusinessCalendar()) {
+		reason, next, ok := notSellDayReason(input, s.Schedule)
+		if ok && units > 0 {
+			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円)", next.Format("2006-01-02"), units, jpy)
+			reason += s.baseNote(input)
+		}
+		return SellDecision{Reason: reason + s.coverageWarning(input)}
+	}
+
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return SellDecision{Reason: "過去の価格データがありません"}
+	}
+	if units <= 0 {
+		return SellDecision{Reason: "保有口数がありません"}
+	}
+
+	amount := s.AmountJPY * s.indexFactor(input)
+	reason := fmt.Sprintf("%s は売却日です. %.0f 円を受け取るため, %d口 (%.0f 円) を売却します", input.Date.Format("2006-01-02"), amount, units, float64(units)*unitPrice)
+	reason += s.baseNote(input)
+	if units == input.Portfolio.TotalUnits {
+		reason += "\n保有口数の全てを売却します"
+	}
+
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      units,
+		ExpectedProceeds: jpy,
+		Reason:           reason + s.coverageWarning(input),
+	}
+}
+
+// 残りの保有資産が現在の金額で何回分あるかを調べ, WarnPeriods を下回る場合は警告を返す
+func (s *FixedAmountStrategy) coverageWarning(input AnalysisInput) string {
+	unitPrice, ok := input.LatestUnitPrice()
+	if s.WarnPeriods <= 0 || !ok || input.Portfolio.TotalUnits <= 0 {
+		return ""
+	}
+	amount := s.AmountJPY * s.indexFactor(input)
+	periods := float64(input.Portfolio.TotalUnits) * unitPrice / amount
+	if periods >= float64(s.WarnPeriods) {
+		return ""
+	}
+	return fmt.Sprintf("\n⚠️ 残りの保有資産 (%.0f 円) は現在の金額で約 %.1f 回分です (警告の基準: %d 回)",
+		float64(input.Portfolio.TotalUnits)*unitPrice, periods, s.WarnPeriods)
+}
@@ -0,0 +1,110 @@
+// internal/strategy/fixed_rate.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/schedule"
+	"time"
+)
+
+// 定率取り崩し
+// 保有口数の評価額に対する年率 AnnualRate を, その年の売却日の数で等分した割合ずつ売却する
+type FixedRateStrategy struct {
+	AnnualRate  float64            // 1年間に売却する評価額の割合
+	Schedule    *schedule.Schedule // 売却日の規則
+	MinOrderJPY float64            // 売却代金の見込みがこの金額に満たない場合は売却しない
+}
+
+func init() {
+	Register("fixed_rate", "保有資産の評価額の一定割合 (年率) を, 売却日ごとに分割して売却する (定率取り崩し)", func() Strategy {
+		return NewFixedRateStrategy()
+	})
+}
+
+// 既定の引数で FixedRateStrategy を生成
+func NewFixedRateStrategy() *FixedRateStrategy {
+	return &FixedRateStrategy{
+		AnnualRate:  0.04,
+		Schedule:    schedule.Weekly(time.Sunday),
+		MinOrderJPY: 100,
+	}
+}
+
+func (s *FixedRateStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "annual_rate", Type: ParamFloat, Default: "0.04", Min: bound(0.001), Max: bound(1),
+			Description: "1年間に売却する評価額の割合 (売却日の数で等分する)"},
+		scheduleParam("weekly:sun", ""),
+		{Name: "min_order_jpy", Type: ParamFloat, Default: "100", Min: bound(0),
+			Description: "最小注文金額 (円). 売却代金の見込みがこれに満たない場合は売却しない"},
+	}
+}
+
+func (s *FixedRateStrategy) Configure(values ParamValues) error {
+	sched, err := schedule.Parse(values.String("schedule"))
+	if err != nil {
+		return fmt.Errorf("売却日の規則: %w", err)
+	}
+	s.AnnualRate = values.Float("annual_rate")
+	s.Schedule = sched
+	s.MinOrderJPY = values.Float("min_order_jpy")
+	return nil
+}
+
+func (s *FixedRateStrategy) SellSchedule() *schedule.Schedule {
+	return s.Schedule
+}
+
+// 1回の売却日に売却する評価額の割合
+func (s *FixedRateStrategy) sliceRate(input AnalysisInput) float64 {
+	n := s.Schedule.CountInYear(input.Date.Year(), input.BusinessCalendar())
+	if n == 0 {
+		return 0
+	}
+	return s.AnnualRate / float64(n)
+}
+
+// 売却日に売却する口数と金額の見込み
+// 評価額に 1回分の割合を掛けた金額を, 最新の基準価額で口数に換算して切り捨てる
+func (s *FixedRateStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	value := float64(input.Portfolio.TotalUnits) * unitPrice
+	units = min(int(value*s.sliceRate(input)/unitPrice), input.Portfolio.TotalUnits)
+	return units, float64(units) * unitPrice
+}
+
+func (s *FixedRateStrategy) Decide(input AnalysisInput) SellDecision {
+	units, jpy := s.Target(input)
+
+	if !s.Schedule.Is(input.Date, input.BusinessCalendar()) {
+		reason, next, ok := notSellDayReason(input, s.Schedule)
+		if ok && units > 0 {
+			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円)", next.Format("2006-01-02"), units, jpy)
+		}
+		return SellDecision{Reason: reason}
+	}
+
+	if _, ok := input.LatestUnitPrice(); !ok {
+		return SellDecision{Reason: "過去の価格データがありません"}
+	}
+	if input.Portfolio.TotalUnits <= 0 {
+		return SellDecision{Reason: "保有口数がありません"}
+	}
+	if units < 1 || jpy < s.MinOrderJPY {
+		return SellDecision{
+			Reason: fmt.Sprintf("売却代金の見込み (%.0f 円) が最小注文金額 (%.0f 円) に満たないため, 売却しません", jpy, s.MinOrderJPY),
+		}
+	}
+
+	n := s.Schedule.CountInYear(input.Date.Year(), input.BusinessCalendar())
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      units,
+		ExpectedProceeds: jpy,
+		Reason: fmt.Sprintf("%s は売却日です. 評価額の年 %.2f%% を %d回に分けて売却するため, %d口 (%.0f 円) を売却します",
+			input.Date.Format("2006-01-02"), s.AnnualRate*100, n, units, jpy),
+	}
+}
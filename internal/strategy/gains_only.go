@@ -0,0 +1,134 @@
+// internal/strategy/gains_only.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/schedule"
+	"math"
+	"strings"
+)
+
+// 利益確定のみ
+//
+// 売却日に, 評価額のうち基準額 * (1 + KeepGain) を超える分だけを売却し, 元本には手を付けない
+// 基準額は取得原価と元本のうち大きい方とする. 1口あたりの取得原価は売却しても変わらないため,
+// 評価益を売却した後も残りの口数には評価益が残り, 取得原価だけを基準にすると売却を繰り返すたびに元本が減っていく
+type GainsOnlyStrategy struct {
+	KeepGain    float64            // 基準額に対して売却せずに残す評価益の割合
+	Schedule    *schedule.Schedule // 売却日の規則
+	MinOrderJPY float64            // 売却代金の見込みがこの金額に満たない場合は売却しない
+}
+
+func init() {
+	Register("gains_only", "売却日に評価益 (評価額のうち元本を超える分) だけを売却し, 元本には手を付けない (利益確定のみ)", func() Strategy {
+		return NewGainsOnlyStrategy()
+	})
+}
+
+// 既定の引数で GainsOnlyStrategy を生成
+func NewGainsOnlyStrategy() *GainsOnlyStrategy {
+	sched, _ := schedule.Parse("bizday:1")
+	return &GainsOnlyStrategy{
+		Schedule:    sched,
+		MinOrderJPY: 1000,
+	}
+}
+
+func (s *GainsOnlyStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "keep_gain", Type: ParamFloat, Default: "0", Min: bound(0), Max: bound(10),
+			Description: "元本に対して売却せずに残す評価益の割合 (例: 0.1 で元本の 110% を超える分だけを売却する)"},
+		scheduleParam("bizday:1", ""),
+		{Name: "min_order_jpy", Type: ParamFloat, Default: "1000", Min: bound(0),
+			Description: "最小注文金額 (円). 売却代金の見込みがこれに満たない場合は売却しない"},
+	}
+}
+
+func (s *GainsOnlyStrategy) Configure(values ParamValues) error {
+	sched, err := schedule.Parse(values.String("schedule"))
+	if err != nil {
+		return fmt.Errorf("売却日の規則: %w", err)
+	}
+	s.Schedule = sched
+	s.KeepGain = values.Float("keep_gain")
+	s.MinOrderJPY = values.Float("min_order_jpy")
+	return nil
+}
+
+func (s *GainsOnlyStrategy) SellSchedule() *schedule.Schedule {
+	return s.Schedule
+}
+
+// 評価額と, 売却してよい金額 (評価額のうち基準額 * (1 + KeepGain) を超える分)
+func (s *GainsOnlyStrategy) harvestable(input AnalysisInput) (value, amount float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	p := input.Portfolio
+	value = float64(p.TotalUnits) * unitPrice
+	base := float64(max(p.CostBasis, p.Principal)) * (1 + s.KeepGain)
+	return value, math.Max(value-base, 0)
+}
+
+// 売却日に売却する口数と金額の見込み
+// 売却してよい金額を最新の基準価額で口数に換算し, 元本を割り込まないよう切り捨てる
+func (s *GainsOnlyStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	_, amount := s.harvestable(input)
+	units = min(int(amount/unitPrice), input.Portfolio.TotalUnits)
+	return units, float64(units) * unitPrice
+}
+
+func (s *GainsOnlyStrategy) Decide(input AnalysisInput) SellDecision {
+	units, jpy := s.Target(input)
+
+	if !s.Schedule.Is(input.Date, input.BusinessCalendar()) {
+		reason, next, ok := notSellDayReason(input, s.Schedule)
+		if ok && units > 0 {
+			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円, 現在の基準価額の場合)", next.Format("2006-01-02"), units, jpy)
+		}
+		return SellDecision{Reason: reason}
+	}
+
+	if _, ok := input.LatestUnitPrice(); !ok {
+		return SellDecision{Reason: "過去の価格データがありません"}
+	}
+	if input.Portfolio.TotalUnits <= 0 {
+		return SellDecision{Reason: "保有口数がありません"}
+	}
+
+	value, amount := s.harvestable(input)
+	p := input.Portfolio
+	reason := fmt.Sprintf("%s は売却日です. 評価額 %.0f 円, 取得原価 %d 円, 元本 %d 円", input.Date.Format("2006-01-02"), value, p.CostBasis, p.Principal)
+	if amount <= 0 {
+		return SellDecision{Reason: reason + "\n評価額が元本を超えていないため, 売却しません"}
+	}
+	if units < 1 || jpy < s.MinOrderJPY {
+		return SellDecision{
+			Reason: fmt.Sprintf("%s\n売却代金の見込み (%.0f 円) が最小注文金額 (%.0f 円) に満たないため, 売却しません", reason, jpy, s.MinOrderJPY),
+		}
+	}
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      units,
+		ExpectedProceeds: jpy,
+		Reason:           fmt.Sprintf("%s\n元本を超える評価益 %.0f 円のうち, %d口 (%.0f 円) を売却します", reason, amount, units, jpy),
+	}
+}
+
+// 取得原価, 元本と評価損益
+func (s *GainsOnlyStrategy) Status(input AnalysisInput) string {
+	var b strings.Builder
+	p := input.Portfolio
+	value, amount := s.harvestable(input)
+	fmt.Fprintf(&b, "保有口数: %d 口\n", p.TotalUnits)
+	fmt.Fprintf(&b, "取得原価: %d 円\n", p.CostBasis)
+	fmt.Fprintf(&b, "元本: %d 円\n", p.Principal)
+	fmt.Fprintf(&b, "評価額: %.0f 円 (評価損益: %+.0f 円)\n", value, value-float64(p.CostBasis))
+	fmt.Fprintf(&b, "売却してよい金額: %.0f 円 (元本の %.0f%% を超える分)\n", amount, (1+s.KeepGain)*100)
+	return b.String()
+}
@@ -0,0 +1,213 @@
+// internal/strategy/guardrails.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/schedule"
+	"math"
+	"time"
+)
+
+// ガードレール付き取り崩し (Guyton-Klinger 方式)
+//
+// 最初の売却は評価額の InitialRate (年率) を売却日の数で等分した金額とし, 以降は前回の売却代金を引き継ぐ
+// 年が変わって最初の売却日には, 次の順に金額を見直す
+//   - 前年の基準価額が下落していなければ, InflationRate だけ引き上げる
+//   - 現在の取り崩し率 (年換算の金額 / 評価額) が InitialRate * (1 + UpperGuardrail) を超えたら Adjustment だけ減らす
+//   - 現在の取り崩し率が InitialRate * (1 - LowerGuardrail) を下回ったら Adjustment だけ増やす
+//
+// 前回の売却代金は取引履歴のうち, 売却日に記録された直近の売却から読み取る
+// 売却日以外の売却 (手動の売却や他の戦略による売却) は引き継がない
+type GuardrailsStrategy struct {
+	InitialRate    float64
+	UpperGuardrail float64
+	LowerGuardrail float64
+	Adjustment     float64
+	InflationRate  float64
+	Schedule       *schedule.Schedule
+}
+
+func init() {
+	Register("guardrails", "初期の取り崩し率から始め, 取り崩し率が上限・下限のガードレールを越えたら金額を減らす・増やす. 下落した年の翌年は物価調整を見送る (Guyton-Klinger 方式)", func() Strategy {
+		return NewGuardrailsStrategy()
+	})
+}
+
+// 既定の引数で GuardrailsStrategy を生成
+func NewGuardrailsStrategy() *GuardrailsStrategy {
+	sched, _ := schedule.Parse("bizday:1")
+	return &GuardrailsStrategy{
+		InitialRate:    0.05,
+		UpperGuardrail: 0.2,
+		LowerGuardrail: 0.2,
+		Adjustment:     0.1,
+		InflationRate:  0.02,
+		Schedule:       sched,
+	}
+}
+
+func (s *GuardrailsStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "initial_rate", Type: ParamFloat, Default: "0.05", Min: bound(0.001), Max: bound(1),
+			Description: "最初の1年間に売却する評価額の割合 (売却日の数で等分する)"},
+		{Name: "upper_guardrail", Type: ParamFloat, Default: "0.2", Min: bound(0), Max: bound(10),
+			Description: "取り崩し率が initial_rate の (1 + この値) 倍を超えたら金額を減らす"},
+		{Name: "lower_guardrail", Type: ParamFloat, Default: "0.2", Min: bound(0), Max: bound(1),
+			Description: "取り崩し率が initial_rate の (1 - この値) 倍を下回ったら金額を増やす"},
+		{Name: "adjustment", Type: ParamFloat, Default: "0.1", Min: bound(0), Max: bound(1),
+			Description: "ガードレールを越えた際に金額を増減する割合"},
+		{Name: "inflation_rate", Type: ParamFloat, Default: "0.02", Min: bound(-0.5), Max: bound(1),
+			Description: "毎年金額を引き上げる割合 (前年の基準価額が下落した場合は見送る)"},
+		scheduleParam("bizday:1", ""),
+	}
+}
+
+func (s *GuardrailsStrategy) Configure(values ParamValues) error {
+	sched, err := schedule.Parse(values.String("schedule"))
+	if err != nil {
+		return fmt.Errorf("売却日の規則: %w", err)
+	}
+	s.Schedule = sched
+	s.InitialRate = values.Float("initial_rate")
+	s.UpperGuardrail = values.Float("upper_guardrail")
+	s.LowerGuardrail = values.Float("lower_guardrail")
+	s.Adjustment = values.Float("adjustment")
+	s.InflationRate = values.Float("inflation_rate")
+	return nil
+}
+
+func (s *GuardrailsStrategy) SellSchedule() *schedule.Schedule {
+	return s.Schedule
+}
+
+// 今回の売却代金の目標と, その決め方の説明
+func (s *GuardrailsStrategy) plan(input AnalysisInput, date time.Time) (float64, []string) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, nil
+	}
+	value := float64(input.Portfolio.TotalUnits) * unitPrice
+	periods := float64(max(s.Schedule.CountInYear(date.Year(), input.BusinessCalendar()), 1))
+
+	lastDate, lastAmount, found := s.lastSell(input)
+	if !found {
+		amount := value * s.InitialRate / periods
+		return amount, []string{fmt.Sprintf("初回: 評価額 %.0f 円の年 %.2f%% を %.0f回に分けた金額", value, s.InitialRate*100, periods)}
+	}
+
+	amount := float64(lastAmount)
+	notes := []string{fmt.Sprintf("前回 (%s) の売却代金 %d 円を引き継ぎ", lastDate.Format("2006-01-02"), lastAmount)}
+	if lastDate.Year() >= date.Year() {
+		return amount, notes
+	}
+
+	// 年が変わって最初の売却日の見直し
+	if r, ok := yearReturn(input.HistoricalPrices, date.Year()-1); ok && r < 0 {
+		notes = append(notes, fmt.Sprintf("%d年の基準価額が %.2f%% 下落したため物価調整を見送り", date.Year()-1, r*100))
+	} else if s.InflationRate != 0 {
+		amount *= 1 + s.InflationRate
+		notes = append(notes, fmt.Sprintf("物価調整で %.2f%% 引き上げ", s.InflationRate*100))
+	}
+
+	if value > 0 {
+		rate := amount * periods / value
+		switch {
+		case rate > s.InitialRate*(1+s.UpperGuardrail):
+			amount *= 1 - s.Adjustment
+			notes = append(notes, fmt.Sprintf("取り崩し率 %.2f%% が上限 %.2f%% を超えたため %.0f%% 減額",
+				rate*100, s.InitialRate*(1+s.UpperGuardrail)*100, s.Adjustment*100))
+		case rate < s.InitialRate*(1-s.LowerGuardrail):
+			amount *= 1 + s.Adjustment
+			notes = append(notes, fmt.Sprintf("取り崩し率 %.2f%% が下限 %.2f%% を下回ったため %.0f%% 増額",
+				rate*100, s.InitialRate*(1-s.LowerGuardrail)*100, s.Adjustment*100))
+		}
+	}
+	return amount, notes
+}
+
+// 売却日に記録された直近の売却の日付と売却代金
+func (s *GuardrailsStrategy) lastSell(input AnalysisInput) (time.Time, int, bool) {
+	cal := input.BusinessCalendar()
+	for i := len(input.Transactions) - 1; i >= 0; i-- {
+		tx := input.Transactions[i]
+		if tx.Type != "sell" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, tx.Datetime)
+		if err != nil {
+			continue
+		}
+		t = t.In(input.Date.Location())
+		if !s.Schedule.Is(t, cal) {
+			continue
+		}
+		return t, tx.AmountJPY, true
+	}
+	return time.Time{}, 0, false
+}
+
+// year 年の基準価額の騰落率 (前年末の最後の価格から, その年の最後の価格まで)
+func yearReturn(prices []DailyPrice, year int) (float64, bool) {
+	start, end := 0, 0
+	startLimit := fmt.Sprintf("%04d-12-31", year-1)
+	endLimit := fmt.Sprintf("%04d-12-31", year)
+	for _, p := range prices {
+		if p.Date <= startLimit {
+			start = p.Price
+		}
+		if p.Date <= endLimit {
+			end = p.Price
+		}
+	}
+	if start <= 0 || end <= 0 {
+		return 0, false
+	}
+	return float64(end)/float64(start) - 1, true
+}
+
+// 売却日に売却する口数と金額の見込み
+// 前回の売却代金を引き継ぐため, 端数の切り上げで金額が少しずつ増えないよう口数は四捨五入する
+func (s *GuardrailsStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	date := input.Date
+	if next, ok := s.Schedule.Next(date, input.BusinessCalendar()); ok {
+		date = next
+	}
+	amount, _ := s.plan(input, date)
+	units = min(int(math.Round(amount/unitPrice)), input.Portfolio.TotalUnits)
+	return units, float64(units) * unitPrice
+}
+
+func (s *GuardrailsStrategy) Decide(input AnalysisInput) SellDecision {
+	units, jpy := s.Target(input)
+
+	if !s.Schedule.Is(input.Date, input.BusinessCalendar()) {
+		reason, next, ok := notSellDayReason(input, s.Schedule)
+		if ok && units > 0 {
+			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円)", next.Format("2006-01-02"), units, jpy)
+		}
+		return SellDecision{Reason: reason}
+	}
+
+	if _, ok := input.LatestUnitPrice(); !ok {
+		return SellDecision{Reason: "過去の価格データがありません"}
+	}
+	if units <= 0 {
+		return SellDecision{Reason: "保有口数がありません"}
+	}
+
+	amount, notes := s.plan(input, input.Date)
+	reason := fmt.Sprintf("%s は売却日です. 目標の売却代金 %.0f 円に対し, %d口 (%.0f 円) を売却します", input.Date.Format("2006-01-02"), amount, units, jpy)
+	for _, n := range notes {
+		reason += "\n  - " + n
+	}
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      units,
+		ExpectedProceeds: jpy,
+		Reason:           reason,
+	}
+}
@@ -0,0 +1,267 @@
+// internal/strategy/ladder.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/data"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 基準価額の段による利益確定
+//
+// 基準価額が Levels の各段に初めて届いたとき, その時点の保有口数の Fractions の割合を売却する
+// Step を指定した場合は, 最後の段より上にも Step ごとに段を続ける
+//
+// 段に到達済みかどうかは取引履歴から判断する. Since 以降の売却のうち, 売却日 (またはそれ以前で最も近い日) に記録された
+// 基準価額以下のまだ到達していない段の割合どおりに売却したものがあれば, それらの段を到達済みとする
+// 売却は翌日以降の基準価額で約定するため, 約定価格ではなく判断に使った価格で比べる
+// 段の割合と口数が合わない売却 (手動の売却や他の戦略による売却) では段を到達済みにしない
+type LadderStrategy struct {
+	Levels      []float64 // 基準価額の段 (昇順)
+	Fractions   []float64 // 段ごとに売却する保有口数の割合 (1つだけの場合は全ての段に使う)
+	Step        float64   // 最後の段より上の段の間隔 (0 の場合は続けない)
+	Since       time.Time // この日以降の売却だけで到達済みの段を判断する (ゼロ値の場合は全ての売却)
+	MinOrderJPY float64   // 売却代金の見込みがこの金額に満たない場合は売却しない
+}
+
+func init() {
+	Register("ladder", "基準価額があらかじめ決めた段に届くたびに, 保有口数の一定割合を売却する (段階的な利益確定)", func() Strategy {
+		return NewLadderStrategy()
+	})
+}
+
+// 既定の引数で LadderStrategy を生成 (段は Configure で指定する)
+func NewLadderStrategy() *LadderStrategy {
+	return &LadderStrategy{
+		Fractions:   []float64{0.05},
+		MinOrderJPY: 100,
+	}
+}
+
+func (s *LadderStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "levels", Type: ParamFloatList, Default: "", Min: bound(1),
+			Description: "基準価額の段 (昇順, 例: 30000,32000,34000)"},
+		{Name: "fractions", Type: ParamFloatList, Default: "0.05", Min: bound(0.0001), Max: bound(1),
+			Description: "段ごとに売却する保有口数の割合. 1つだけ指定した場合は全ての段に使う"},
+		{Name: "step", Type: ParamFloat, Default: "0", Min: bound(0),
+			Description: "最後の段より上にもこの間隔で段を続ける (0 で続けない). 割合は fractions の最後の値を使う"},
+		{Name: "since", Type: ParamString, Default: "",
+			Description: "この日 (YYYY-MM-DD) 以降の売却だけで到達済みの段を判断する (省略時は全ての売却)"},
+		{Name: "min_order_jpy", Type: ParamFloat, Default: "100", Min: bound(0),
+			Description: "最小注文金額 (円). 売却代金の見込みがこれに満たない場合は売却しない"},
+	}
+}
+
+func (s *LadderStrategy) Configure(values ParamValues) error {
+	s.Levels = values.FloatList("levels")
+	s.Fractions = values.FloatList("fractions")
+	s.Step = values.Float("step")
+	s.MinOrderJPY = values.Float("min_order_jpy")
+
+	if len(s.Levels) == 0 {
+		return fmt.Errorf("levels を指定してください")
+	}
+	for i := 1; i < len(s.Levels); i++ {
+		if s.Levels[i] <= s.Levels[i-1] {
+			return fmt.Errorf("levels は昇順で指定してください")
+		}
+	}
+	if len(s.Fractions) != 1 && len(s.Fractions) != len(s.Levels) {
+		return fmt.Errorf("fractions は 1つ, または levels と同じ数 (%d) を指定してください", len(s.Levels))
+	}
+
+	s.Since = time.Time{}
+	if date := values.String("since"); date != "" {
+		var err error
+		if s.Since, err = time.ParseInLocation("2006-01-02", date, time.Local); err != nil {
+			return fmt.Errorf("since の日付が不正です: %q", date)
+		}
+	}
+	return nil
+}
+
+// 段
+type rung struct {
+	Level    float64
+	Fraction float64
+	Done     bool
+	DoneOn   time.Time // 到達済みと判断した売却の日
+}
+
+// i 番目の段の基準価額 (段がない場合は +Inf)
+func (s *LadderStrategy) level(i int) float64 {
+	if i < len(s.Levels) {
+		return s.Levels[i]
+	}
+	if s.Step <= 0 {
+		return math.Inf(1)
+	}
+	last := len(s.Levels) - 1
+	return s.Levels[last] + s.Step*float64(i-last)
+}
+
+// i 番目の段の売却割合
+func (s *LadderStrategy) fraction(i int) float64 {
+	return s.Fractions[min(i, len(s.Fractions)-1)]
+}
+
+// 基準価額 upTo 以下の段と, その上の段を最大 above 個, 到達済みかどうかとともに返す
+func (s *LadderStrategy) rungs(input AnalysisInput, upTo float64, above int) []rung {
+	var out []rung
+	for i := 0; ; i++ {
+		level := s.level(i)
+		if math.IsInf(level, 1) {
+			break
+		}
+		if level > upTo {
+			if above <= 0 {
+				break
+			}
+			above--
+		}
+		out = append(out, rung{Level: level, Fraction: s.fraction(i)})
+	}
+
+	done := s.doneRungs(input)
+	for i := range out {
+		out[i].DoneOn, out[i].Done = done[i]
+	}
+	return out
+}
+
+// 到達済みの段 (段の番号 -> 到達済みと判断した売却の日)
+// Since 以降の売却ごとに, その日の基準価額以下でまだ到達していない段をまとめて売却した場合の口数を求め,
+// 売却した口数がそれと一致する (1% または 1口の誤差まで) 場合だけ, それらの段を到達済みとする
+func (s *LadderStrategy) doneRungs(input AnalysisInput) map[int]time.Time {
+	done := make(map[int]time.Time)
+	prices := input.HistoricalPrices
+	var status data.PortfolioStatus
+	for _, tx := range input.Transactions {
+		if tx.Type == "buy" {
+			status.ApplyBuy(tx.AmountJPY, tx.Units)
+		}
+		if tx.Type != "sell" {
+			continue
+		}
+		holdings := status.TotalUnits
+		status.ApplySell(tx.AmountJPY, tx.Units)
+
+		t, err := time.Parse(time.RFC3339, tx.Datetime)
+		if err != nil || tx.Units <= 0 || holdings <= 0 || (!s.Since.IsZero() && t.Before(s.Since)) {
+			continue
+		}
+		t = t.In(input.Date.Location())
+		// 売却日以前で最も新しい基準価額. 記録がない場合は約定価格 (売却代金は円未満を切り捨てて記録されるため, 1円分の誤差を許す)
+		key := t.Format("2006-01-02")
+		price := float64(tx.AmountJPY+1) * 10000 / float64(tx.Units)
+		if j := sort.Search(len(prices), func(j int) bool { return prices[j].Date > key }) - 1; j >= 0 {
+			price = float64(prices[j].Price)
+		}
+
+		var pending []int
+		keep := 1.0
+		for i := 0; s.level(i) <= price; i++ {
+			if _, ok := done[i]; !ok {
+				pending = append(pending, i)
+				keep *= 1 - s.fraction(i)
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		expected := int(float64(holdings) * (1 - keep))
+		if diff := tx.Units - expected; diff < -max(1, expected/100) || diff > max(1, expected/100) {
+			continue
+		}
+		for _, i := range pending {
+			done[i] = t
+		}
+	}
+	return done
+}
+
+func (s *LadderStrategy) Decide(input AnalysisInput) SellDecision {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return SellDecision{Reason: "過去の価格データがありません"}
+	}
+	if input.Portfolio.TotalUnits <= 0 {
+		return SellDecision{Reason: "保有口数がありません"}
+	}
+	price := unitPrice * 10000
+
+	keep := 1.0
+	var reached []string
+	var next *rung
+	for _, r := range s.rungs(input, price, 1) {
+		switch {
+		case r.Level > price:
+			next = &r
+		case !r.Done:
+			keep *= 1 - r.Fraction
+			reached = append(reached, fmt.Sprintf("%.0f", r.Level))
+		}
+	}
+
+	if len(reached) == 0 {
+		if next == nil {
+			return SellDecision{Reason: fmt.Sprintf("基準価額 %.0f 以下の段は全て到達済みで, これより上の段はありません", price)}
+		}
+		return SellDecision{Reason: fmt.Sprintf("基準価額 %.0f は次の段 %.0f に届いていません (あと %.0f)", price, next.Level, next.Level-price)}
+	}
+
+	fraction := 1 - keep
+	units := int(float64(input.Portfolio.TotalUnits) * fraction)
+	jpy := float64(units) * unitPrice
+	reason := fmt.Sprintf("基準価額 %.0f が段 %s に到達したため, 保有口数の %.2f%% を売却します", price, strings.Join(reached, ", "), fraction*100)
+	if units < 1 || jpy < s.MinOrderJPY {
+		return SellDecision{
+			Reason: fmt.Sprintf("%s\n売却代金の見込み (%.0f 円) が最小注文金額 (%.0f 円) に満たないため, 売却しません", reason, jpy, s.MinOrderJPY),
+		}
+	}
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      units,
+		ExpectedProceeds: jpy,
+		Reason:           fmt.Sprintf("%s\n%d口 (%.0f 円) を売却します", reason, units, jpy),
+	}
+}
+
+// 段の一覧と到達状況
+func (s *LadderStrategy) Status(input AnalysisInput) string {
+	var b strings.Builder
+	price := 0.0
+	if unitPrice, ok := input.LatestUnitPrice(); ok {
+		price = unitPrice * 10000
+		last := input.HistoricalPrices[len(input.HistoricalPrices)-1]
+		fmt.Fprintf(&b, "現在の基準価額: %.0f (%s)\n", price, last.Date)
+	}
+
+	remaining := 0
+	b.WriteString("段:\n")
+	for _, r := range s.rungs(input, price, 3) {
+		switch {
+		case r.Done:
+			fmt.Fprintf(&b, "  ✓ %8.0f  %6.2f%%  到達済み (%s の売却)\n", r.Level, r.Fraction*100, r.DoneOn.Format("2006-01-02"))
+		case r.Level <= price:
+			remaining++
+			fmt.Fprintf(&b, "  ! %8.0f  %6.2f%%  到達 (次回の判断で売却)\n", r.Level, r.Fraction*100)
+		case price > 0:
+			remaining++
+			fmt.Fprintf(&b, "    %8.0f  %6.2f%%  あと %.0f (%.2f%%)\n", r.Level, r.Fraction*100, r.Level-price, (r.Level/price-1)*100)
+		default:
+			remaining++
+			fmt.Fprintf(&b, "    %8.0f  %6.2f%%\n", r.Level, r.Fraction*100)
+		}
+	}
+	if s.Step > 0 {
+		fmt.Fprintf(&b, "  以降 %.0f ごとに %.2f%%\n", s.Step, s.fraction(len(s.Fractions)-1)*100)
+	} else {
+		fmt.Fprintf(&b, "残りの段: %d\n", remaining)
+	}
+	return b.String()
+}
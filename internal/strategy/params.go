@@ -0,0 +1,275 @@
+// internal/strategy/params.go
+package strategy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 引数の型
+type ParamType string
+
+const (
+	ParamFloat     ParamType = "float"
+	ParamInt       ParamType = "int"
+	ParamBool      ParamType = "bool"
+	ParamString    ParamType = "string"
+	ParamFloatList ParamType = "float_list" // カンマ区切りの数値
+)
+
+// 戦略の引数の定義
+type ParamSpec struct {
+	Name        string
+	Type        ParamType
+	Default     string // 既定値 (文字列表現)
+	Description string
+	Min, Max    *float64 // 数値の範囲 (nil の場合は制限なし)
+	Choices     []string // ParamString で指定できる値 (空の場合は制限なし)
+}
+
+// 引数を持つ戦略
+type Configurable interface {
+	Strategy
+	Params() []ParamSpec
+	Configure(values ParamValues) error
+}
+
+// 解決済みの引数 (名前 -> 型に応じた値)
+type ParamValues map[string]any
+
+func (v ParamValues) Float(name string) float64 {
+	f, _ := v[name].(float64)
+	return f
+}
+
+func (v ParamValues) Int(name string) int {
+	i, _ := v[name].(int)
+	return i
+}
+
+func (v ParamValues) Bool(name string) bool {
+	b, _ := v[name].(bool)
+	return b
+}
+
+func (v ParamValues) String(name string) string {
+	s, _ := v[name].(string)
+	return s
+}
+
+func (v ParamValues) FloatList(name string) []float64 {
+	l, _ := v[name].([]float64)
+	return l
+}
+
+// 数値の範囲を指定するための補助
+func bound(f float64) *float64 {
+	return &f
+}
+
+// 既定値に layers を順に重ねて引数を解決する
+// 後の layer ほど優先され, 定義にない名前や範囲外の値はエラーとする
+func ResolveParams(specs []ParamSpec, layers ...map[string]string) (ParamValues, error) {
+	byName := make(map[string]ParamSpec)
+	raw := make(map[string]string)
+	for _, spec := range specs {
+		byName[spec.Name] = spec
+		raw[spec.Name] = spec.Default
+	}
+	for _, layer := range layers {
+		for name, value := range layer {
+			if _, ok := byName[name]; !ok {
+				return nil, fmt.Errorf("引数 %q は定義されていません", name)
+			}
+			raw[name] = value
+		}
+	}
+
+	values := make(ParamValues)
+	for _, spec := range specs {
+		v, err := parseParam(spec, raw[spec.Name])
+		if err != nil {
+			return nil, fmt.Errorf("引数 %s: %w", spec.Name, err)
+		}
+		values[spec.Name] = v
+	}
+	return values, nil
+}
+
+func parseParam(spec ParamSpec, s string) (any, error) {
+	s = strings.TrimSpace(s)
+	switch spec.Type {
+	case ParamFloat:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("数値で指定してください: %q", s)
+		}
+		return f, checkRange(spec, f)
+	case ParamInt:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("整数で指定してください: %q", s)
+		}
+		return i, checkRange(spec, float64(i))
+	case ParamBool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("true または false で指定してください: %q", s)
+		}
+		return b, nil
+	case ParamString:
+		if len(spec.Choices) > 0 {
+			for _, c := range spec.Choices {
+				if s == c {
+					return s, nil
+				}
+			}
+			return nil, fmt.Errorf("%s のいずれかで指定してください: %q", strings.Join(spec.Choices, ", "), s)
+		}
+		return s, nil
+	case ParamFloatList:
+		var list []float64
+		if s == "" {
+			return list, nil
+		}
+		for _, part := range strings.Split(s, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, fmt.Errorf("カンマ区切りの数値で指定してください: %q", s)
+			}
+			if err := checkRange(spec, f); err != nil {
+				return nil, err
+			}
+			list = append(list, f)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("未知の型です: %s", spec.Type)
+}
+
+func checkRange(spec ParamSpec, f float64) error {
+	if spec.Min != nil && f < *spec.Min {
+		return fmt.Errorf("%v 以上で指定してください: %v", *spec.Min, f)
+	}
+	if spec.Max != nil && f > *spec.Max {
+		return fmt.Errorf("%v 以下で指定してください: %v", *spec.Max, f)
+	}
+	return nil
+}
+
+// 範囲の表示用の文字列
+func (spec ParamSpec) RangeString() string {
+	switch {
+	case len(spec.Choices) > 0:
+		return strings.Join(spec.Choices, "|")
+	case spec.Min != nil && spec.Max != nil:
+		return fmt.Sprintf("%v〜%v", *spec.Min, *spec.Max)
+	case spec.Min != nil:
+		return fmt.Sprintf("%v〜", *spec.Min)
+	case spec.Max != nil:
+		return fmt.Sprintf("〜%v", *spec.Max)
+	}
+	return ""
+}
+
+// 設定ファイル (JSON) の値を引数の文字列表現に変換する
+func ParamsFromConfig(m map[string]any) map[string]string {
+	out := make(map[string]string, len(m))
+	for name, v := range m {
+		out[name] = configValueString(v)
+	}
+	return out
+}
+
+func configValueString(v any) string {
+	switch x := v.(type) {
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case []any:
+		parts := make([]string, len(x))
+		for i, e := range x {
+			parts[i] = configValueString(e)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// 設定ファイルの strategy_params (戦略名 -> 引数)
+// 他の戦略を包む戦略が, 包んだ戦略の引数を解決する際に使う
+var configParams map[string]map[string]string
+
+// 設定ファイルの strategy_params を登録する
+func SetConfigParams(params map[string]map[string]string) {
+	configParams = params
+}
+
+// "key=value" 形式の指定を解析する
+func ParseParamFlags(flags []string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, f := range flags {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("引数は key=value の形式で指定してください: %q", f)
+		}
+		out[strings.TrimSpace(key)] = value
+	}
+	return out, nil
+}
+
+// 戦略を生成し, 引数を解決して設定する
+func Build(name string, layers ...map[string]string) (Strategy, ParamValues, error) {
+	s, err := New(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, ok := s.(Configurable)
+	if !ok {
+		for _, layer := range layers {
+			if len(layer) > 0 {
+				return nil, nil, fmt.Errorf("戦略 %q は引数を受け付けません", name)
+			}
+		}
+		return s, nil, nil
+	}
+
+	values, err := ResolveParams(c.Params(), layers...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.Configure(values); err != nil {
+		return nil, nil, err
+	}
+	return c, values, nil
+}
+
+// 引数を名前順に "key=value" 形式で並べる
+func (v ParamValues) Strings() []string {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = name + "=" + formatParamValue(v[name])
+	}
+	return out
+}
+
+func formatParamValue(v any) string {
+	switch x := v.(type) {
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case []float64:
+		parts := make([]string, len(x))
+		for i, f := range x {
+			parts[i] = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,49 @@
+// internal/strategy/registry.go
+package strategy
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 設定やフラグで指定がない場合の戦略
+const DefaultName = "simple"
+
+// 登録された戦略
+type Registration struct {
+	Name        string          // 設定や --strategy で指定する名前
+	Description string          // strategy list に表示する説明
+	New         func() Strategy // 戦略の生成
+}
+
+var registry = make(map[string]Registration)
+
+// 戦略を名前で登録する
+// 各戦略のファイルの init から呼び出す
+func Register(name, description string, factory func() Strategy) {
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("strategy: %q は既に登録されています", name))
+	}
+	registry[name] = Registration{Name: name, Description: description, New: factory}
+}
+
+// 名前から戦略を生成する
+func New(name string) (Strategy, error) {
+	r, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("戦略 %q は登録されていません", name)
+	}
+	return r.New(), nil
+}
+
+// 登録された戦略を名前順に返す
+func List() []Registration {
+	list := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
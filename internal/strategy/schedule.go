@@ -0,0 +1,46 @@
+// internal/strategy/schedule.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/schedule"
+	"time"
+)
+
+// 売却日の規則に従う戦略
+// 規則は戦略ごとの引数 schedule で設定する. 他の戦略を包む戦略は, 包んだ戦略が規則を持たない場合に nil を返す
+type Scheduled interface {
+	Strategy
+	SellSchedule() *schedule.Schedule
+}
+
+// 戦略の売却日の規則 (規則を持たない場合は false を返す)
+func SellScheduleOf(s Strategy) (*schedule.Schedule, bool) {
+	if scheduled, ok := s.(Scheduled); ok {
+		if sched := scheduled.SellSchedule(); sched != nil {
+			return sched, true
+		}
+	}
+	return nil, false
+}
+
+// 売却日の規則を指定する引数
+func scheduleParam(def, description string) ParamSpec {
+	if description == "" {
+		description = "売却日の規則"
+	}
+	return ParamSpec{Name: "schedule", Type: ParamString, Default: def,
+		Description: description + " (例: weekly:sun, weekly:fri every=2, monthly:1 roll=forward, bizday:1, bizday:-1)"}
+}
+
+// 基準日が売却日でない場合の理由と, 次回の売却日
+func notSellDayReason(input AnalysisInput, sched *schedule.Schedule) (string, time.Time, bool) {
+	cal := input.BusinessCalendar()
+	today := input.Date
+	reason := fmt.Sprintf("%s (%s) は売却日ではありません (売却日の規則: %s)", today.Format("2006-01-02"), today.Weekday(), sched)
+	if name, ok := cal.HolidayName(today); ok {
+		reason = fmt.Sprintf("%s (%s, %s) は売却日ではありません (売却日の規則: %s)", today.Format("2006-01-02"), today.Weekday(), name, sched)
+	}
+	next, ok := sched.Next(today, cal)
+	return reason, next, ok
+}
@@ -0,0 +1,258 @@
+// internal/strategy/script.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/rules"
+	"kk-invest/internal/schedule"
+	"math"
+	"strings"
+	"time"
+)
+
+// 規則ファイル
+//
+// 売却日に, ファイルに書かれた規則 (rules パッケージの言語) を評価して売却する口数を決める
+// Go で戦略を書かなくても, 基準価額や移動平均, 保有口数などを組み合わせた条件と売却量を記述できる
+type ScriptStrategy struct {
+	File        string             // 規則のファイルのパス
+	Program     *rules.Program     // 読み込んだ規則
+	Schedule    *schedule.Schedule // 売却日の規則
+	MinOrderJPY float64            // 売却代金の見込みがこの金額に満たない場合は売却しない
+}
+
+func init() {
+	Register("script", "ファイルに書いた規則 (条件と sell_pct, sell_jpy などの売却量) に従って売却する. 規則は strategy check で確かめられる", func() Strategy {
+		return NewScriptStrategy()
+	})
+}
+
+// 既定の引数で ScriptStrategy を生成 (規則のファイルは Configure で読み込む)
+func NewScriptStrategy() *ScriptStrategy {
+	sched, _ := schedule.Parse("bizday:1")
+	return &ScriptStrategy{
+		Schedule:    sched,
+		MinOrderJPY: 100,
+	}
+}
+
+func (s *ScriptStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "file", Type: ParamString, Default: "",
+			Description: "規則のファイルのパス (必須)"},
+		scheduleParam("bizday:1", "売却日の規則. 規則は売却日にだけ評価する"),
+		{Name: "min_order_jpy", Type: ParamFloat, Default: "100", Min: bound(0),
+			Description: "最小注文金額 (円). 売却代金の見込みがこれに満たない場合は売却しない"},
+	}
+}
+
+func (s *ScriptStrategy) Configure(values ParamValues) error {
+	sched, err := schedule.Parse(values.String("schedule"))
+	if err != nil {
+		return fmt.Errorf("売却日の規則: %w", err)
+	}
+	s.Schedule = sched
+	s.MinOrderJPY = values.Float("min_order_jpy")
+
+	s.File = values.String("file")
+	if s.File == "" {
+		return fmt.Errorf("file に規則のファイルのパスを指定してください")
+	}
+	s.Program, err = rules.ParseFile(s.File)
+	return err
+}
+
+func (s *ScriptStrategy) SellSchedule() *schedule.Schedule {
+	return s.Schedule
+}
+
+// 規則に渡す変数の値
+func (s *ScriptStrategy) env(input AnalysisInput) *rules.Env {
+	cal := input.BusinessCalendar()
+	date := input.Date
+	p := input.Portfolio
+
+	prices := make([]float64, len(input.HistoricalPrices))
+	for i, dp := range input.HistoricalPrices {
+		prices[i] = float64(dp.Price)
+	}
+	price := 0.0
+	if len(prices) > 0 {
+		price = prices[len(prices)-1]
+	}
+
+	sold := 0
+	for _, tx := range input.Transactions {
+		if tx.Type != "sell" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, tx.Datetime); err == nil && t.In(date.Location()).Year() == date.Year() {
+			sold += tx.AmountJPY
+		}
+	}
+
+	jan1 := time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())
+	perYear := s.Schedule.CountInYear(date.Year(), cal)
+	before := len(s.Schedule.Previous(date, jan1, perYear, cal))
+	sellDay := s.Schedule.Is(date, cal)
+	index := before
+	if sellDay {
+		index++
+	}
+	nextDay := ""
+	if next, ok := s.Schedule.Next(date, cal); ok {
+		nextDay = next.Format("2006-01-02")
+	}
+
+	value := float64(p.TotalUnits) * price / 10000
+	return &rules.Env{
+		Prices: prices,
+		Vars: map[string]any{
+			"price":              price,
+			"history":            float64(len(prices)),
+			"holdings":           float64(p.TotalUnits),
+			"value":              value,
+			"cost":               float64(p.CostBasis),
+			"principal":          float64(p.Principal),
+			"gain":               value - float64(p.CostBasis),
+			"invested":           float64(p.TotalInvestment),
+			"cash":               float64(p.Cash),
+			"sold_this_year":     float64(sold),
+			"date":               date.Format("2006-01-02"),
+			"year":               float64(date.Year()),
+			"month":              float64(date.Month()),
+			"day":                float64(date.Day()),
+			"weekday":            float64(date.Weekday()),
+			"sell_day":           sellDay,
+			"next_sell_day":      nextDay,
+			"sell_days_per_year": float64(perYear),
+			"sell_day_index":     float64(index),
+			"sell_days_left":     float64(perYear - before),
+		},
+	}
+}
+
+// 規則を評価し, 実行された動作と売却する口数を返す
+func (s *ScriptStrategy) evaluate(input AnalysisInput) (rules.Action, int, error) {
+	action, err := s.Program.Evaluate(s.env(input))
+	if err != nil {
+		return rules.Action{}, 0, err
+	}
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return action, 0, nil
+	}
+
+	holdings := input.Portfolio.TotalUnits
+	units := 0
+	switch action.Name {
+	case "sell_pct":
+		units = int(float64(holdings) * action.Amount / 100)
+	case "sell_jpy":
+		units = int(math.Ceil(action.Amount / unitPrice))
+	case "sell_units":
+		units = int(action.Amount)
+	}
+	return action, max(min(units, holdings), 0), nil
+}
+
+// 動作の説明 (例: "3行目 sell_pct(1.5): 利益確定")
+func describeAction(action rules.Action) string {
+	if action.Name == "" {
+		return "どの規則にも当てはまりませんでした"
+	}
+	desc := fmt.Sprintf("%d行目 %s()", action.Line, action.Name)
+	if action.Name != "hold" {
+		desc = fmt.Sprintf("%d行目 %s(%.10g)", action.Line, action.Name, action.Amount)
+	}
+	if action.Note != "" {
+		desc += ": " + action.Note
+	}
+	return desc
+}
+
+// 売却日に売却する口数と金額の見込み (規則の評価に失敗した場合は 0)
+func (s *ScriptStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	_, units, err := s.evaluate(input)
+	if err != nil {
+		return 0, 0
+	}
+	return units, float64(units) * unitPrice
+}
+
+func (s *ScriptStrategy) Decide(input AnalysisInput) SellDecision {
+	if !s.Schedule.Is(input.Date, input.BusinessCalendar()) {
+		reason, next, ok := notSellDayReason(input, s.Schedule)
+		if units, jpy := s.Target(input); ok && units > 0 {
+			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円, 現在の基準価額と保有口数で規則を評価した場合)", next.Format("2006-01-02"), units, jpy)
+		}
+		return SellDecision{Reason: reason}
+	}
+
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return SellDecision{Reason: "過去の価格データがありません"}
+	}
+	if input.Portfolio.TotalUnits <= 0 {
+		return SellDecision{Reason: "保有口数がありません"}
+	}
+
+	action, units, err := s.evaluate(input)
+	if err != nil {
+		return SellDecision{Reason: fmt.Sprintf("規則の評価に失敗したため, 売却しません: %s: %v", s.File, err)}
+	}
+	reason := fmt.Sprintf("%s は売却日です. %s", input.Date.Format("2006-01-02"), describeAction(action))
+	if units <= 0 {
+		return SellDecision{Reason: reason + "\n売却しません"}
+	}
+	jpy := float64(units) * unitPrice
+	if jpy < s.MinOrderJPY {
+		return SellDecision{
+			Reason: fmt.Sprintf("%s\n売却代金の見込み (%.0f 円) が最小注文金額 (%.0f 円) に満たないため, 売却しません", reason, jpy, s.MinOrderJPY),
+		}
+	}
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      units,
+		ExpectedProceeds: jpy,
+		Reason:           fmt.Sprintf("%s\n%d口 (%.0f 円) を売却します", reason, units, jpy),
+	}
+}
+
+// 規則が参照している変数の値と, 現在の入力で規則を評価した結果
+func (s *ScriptStrategy) Status(input AnalysisInput) string {
+	var b strings.Builder
+	stmts, actions := s.Program.Count()
+	fmt.Fprintf(&b, "規則のファイル: %s (%d文, うち動作 %d)\n", s.File, stmts, actions)
+
+	env := s.env(input)
+	if used := s.Program.Used(); len(used) > 0 {
+		b.WriteString("参照している変数:\n")
+		for _, name := range used {
+			switch v := env.Vars[name].(type) {
+			case float64:
+				fmt.Fprintf(&b, "  %-20s %.10g\n", name, v)
+			case string:
+				fmt.Fprintf(&b, "  %-20s %q\n", name, v)
+			default:
+				fmt.Fprintf(&b, "  %-20s %v\n", name, v)
+			}
+		}
+	}
+
+	action, units, err := s.evaluate(input)
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, "評価結果: 失敗しました: %v\n", err)
+	case units > 0:
+		unitPrice, _ := input.LatestUnitPrice()
+		fmt.Fprintf(&b, "評価結果: %s\n  売却日であれば %d口 (%.0f 円) を売却します\n", describeAction(action), units, float64(units)*unitPrice)
+	default:
+		fmt.Fprintf(&b, "評価結果: %s\n  売却しません\n", describeAction(action))
+	}
+	return b.String()
+}
@@ -3,39 +3,99 @@ package strategy
 
 import (
 	"fmt"
+	"kk-invest/internal/schedule"
 	"time"
 )
 
-type SimpleStrategy struct{}
+type SimpleStrategy struct {
+	SellRatio       float64            // 投資元本のうち売却する割合
+	Schedule        *schedule.Schedule // 売却日の規則
+	ThresholdMargin float64            // 評価額が投資元本をこの割合以上上回る場合のみ売却する
+}
 
-func (s *SimpleStrategy) Decide(input AnalysisInput) SellDecision {
-	latestPriceRecord := input.HistoricalPrices[len(input.HistoricalPrices)-1]
-	latestPrice := latestPriceRecord.Price
+func init() {
+	Register("simple", "売却日ごとに投資元本の一定割合を売却する (評価額が元本以下の場合は売却しない)", func() Strategy {
+		return NewSimpleStrategy()
+	})
+}
+
+// 既定の引数で SimpleStrategy を生成
+func NewSimpleStrategy() *SimpleStrategy {
+	return &SimpleStrategy{
+		SellRatio:       0.5,
+		Schedule:        schedule.Weekly(time.Sunday),
+		ThresholdMargin: 0,
+	}
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func (s *SimpleStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "sell_ratio", Type: ParamFloat, Default: "0.5", Min: bound(0.01), Max: bound(1),
+			Description: "投資元本のうち売却する割合"},
+		scheduleParam("", "売却日の規則 (省略時は sell_weekday の曜日に毎週)"),
+		{Name: "sell_weekday", Type: ParamString, Default: "sun", Choices: weekdayNames,
+			Description: "schedule を省略した場合の売却日の曜日 (平日が休業日にあたる場合は翌営業日に繰り下げる)"},
+		{Name: "threshold_margin", Type: ParamFloat, Default: "0", Min: bound(-1), Max: bound(10),
+			Description: "評価額が投資元本を (1 + この値) 倍より上回る場合のみ売却する (スライド売却の閾値)"},
+	}
+}
+
+func (s *SimpleStrategy) Configure(values ParamValues) error {
+	s.SellRatio = values.Float("sell_ratio")
+	s.ThresholdMargin = values.Float("threshold_margin")
+
+	expr := values.String("schedule")
+	if expr == "" {
+		expr = "weekly:" + values.String("sell_weekday")
+	}
+	sched, err := schedule.Parse(expr)
+	if err != nil {
+		return fmt.Errorf("売却日の規則: %w", err)
+	}
+	s.Schedule = sched
+	return nil
+}
+
+func (s *SimpleStrategy) SellSchedule() *schedule.Schedule {
+	return s.Schedule
+}
+
+// 次回の売却日に売却する口数と金額の見込み
+// 投資元本 (購入額 - 売却額) の SellRatio の割合を, 最新の基準価額で口数に換算する
+func (s *SimpleStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	if len(input.HistoricalPrices) == 0 {
+		return 0, 0
+	}
+	latestPrice := input.HistoricalPrices[len(input.HistoricalPrices)-1].Price
 	currentUnitPrice := float64(latestPrice) / 10000.0
 
 	var totalBuyJPY, totalSellJPY int
 	for _, tx := range input.Transactions {
-		if tx.Type == "buy" {
+		switch tx.Type {
+		case "buy":
 			totalBuyJPY += tx.AmountJPY
-		} else {
+		case "sell":
 			totalSellJPY += tx.AmountJPY
 		}
 	}
-	targetSellJPY := float64(totalBuyJPY-totalSellJPY) * 0.5
-	unitsToSell := 0
-	if targetSellJPY > 0 {
-		unitsToSell = int(targetSellJPY / currentUnitPrice)
+	jpy = float64(totalBuyJPY-totalSellJPY) * s.SellRatio
+	if jpy > 0 && currentUnitPrice > 0 {
+		units = int(jpy / currentUnitPrice)
 	}
+	return units, jpy
+}
+
+func (s *SimpleStrategy) Decide(input AnalysisInput) SellDecision {
+	unitsToSell, targetSellJPY := s.Target(input)
 
 	// 売却日かどうかの判定
-	today := time.Now()
-	if today.Weekday() != time.Sunday {
-		daysUntilSunday := (7 - int(today.Weekday())) % 7
-		nextSunday := today.AddDate(0, 0, daysUntilSunday)
-
-		reason := fmt.Sprintf("本日 (%s) は売却日ではありません", today.Weekday())
-		if unitsToSell > 0 {
-			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円)", nextSunday.Format("2006-01-02"), unitsToSell, targetSellJPY)
+	today := input.Date
+	if !s.Schedule.Is(today, input.BusinessCalendar()) {
+		reason, nextSellDay, ok := notSellDayReason(input, s.Schedule)
+		if ok && unitsToSell > 0 {
+			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円)", nextSellDay.Format("2006-01-02"), unitsToSell, targetSellJPY)
 		}
 
 		return SellDecision{
@@ -53,13 +113,20 @@ func (s *SimpleStrategy) Decide(input AnalysisInput) SellDecision {
 		}
 	}
 
+	currentUnitPrice := float64(input.HistoricalPrices[len(input.HistoricalPrices)-1].Price) / 10000.0
 	currentValue := float64(input.Portfolio.TotalUnits) * currentUnitPrice
 
-	if currentValue <= float64(input.Portfolio.TotalInvestment) {
+	threshold := float64(input.Portfolio.TotalInvestment) * (1 + s.ThresholdMargin)
+	if currentValue <= threshold {
+		reason := "現在の評価額が投資元本以下のため, 売却しません (スライド売却)"
+		if s.ThresholdMargin != 0 {
+			reason = fmt.Sprintf("現在の評価額 (%.0f 円) が閾値 (投資元本の %.0f%%: %.0f 円) 以下のため, 売却しません (スライド売却)",
+				currentValue, (1+s.ThresholdMargin)*100, threshold)
+		}
 		return SellDecision{
 			ShouldSell:  false,
 			UnitsToSell: 0,
-			Reason:      "現在の評価額が投資元本以下のため, 売却しません (スライド売却)",
+			Reason:      reason,
 		}
 	}
 
@@ -72,8 +139,9 @@ func (s *SimpleStrategy) Decide(input AnalysisInput) SellDecision {
 	}
 
 	return SellDecision{
-		ShouldSell:  true,
-		UnitsToSell: unitsToSell,
-		Reason:      fmt.Sprintf("本日は売却日です. 現在の評価額が投資元本を上回っているため, %d口 (%.0f 円) を売却します", unitsToSell, targetSellJPY),
+		ShouldSell:       true,
+		UnitsToSell:      unitsToSell,
+		ExpectedProceeds: float64(unitsToSell) * currentUnitPrice,
+		Reason:           fmt.Sprintf("%s は売却日です. 現在の評価額が投資元本を上回っているため, %d口 (%.0f 円) を売却します", today.Format("2006-01-02"), unitsToSell, targetSellJPY),
 	}
 }
@@ -1,7 +1,11 @@
 // internal/strategy/strategy.go
 package strategy
 
-import "kk-invest/internal/data"
+import (
+	"kk-invest/internal/calendar"
+	"kk-invest/internal/data"
+	"time"
+)
 
 type DailyPrice struct {
 	Date  string // 日付 (YYYY-MM-DD)
@@ -9,19 +13,100 @@ type DailyPrice struct {
 }
 
 // 売却判断アルゴリズムが必要とする全ての情報
+// 戦略は現在時刻を参照せず, Date を判断の基準日とする
 type AnalysisInput struct {
-	Transactions     []data.Transaction    // これまでの全取引履歴
-	HistoricalPrices []DailyPrice          // 過去の価格データ
-	Portfolio        *data.PortfolioStatus // 現在のポートフォリオ状況
+	Date             time.Time             // 判断の基準日
+	Transactions     []data.Transaction    // 基準日までの全取引履歴
+	HistoricalPrices []DailyPrice          // 基準日までの価格データ
+	Portfolio        *data.PortfolioStatus // 基準日時点のポートフォリオ状況
+	Calendar         *calendar.Calendar    // 営業日の判定に使うカレンダー
 }
 
 type SellDecision struct {
-	ShouldSell  bool   // 売却すべきかどうか
-	UnitsToSell int    // 売却すべき口数
-	Reason      string // 売却判断の理由
+	ShouldSell       bool    // 売却すべきかどうか
+	UnitsToSell      int     // 売却すべき口数
+	ExpectedProceeds float64 // 最新の基準価額で計算した売却代金の見込み
+	Reason           string  // 売却判断の理由
+}
+
+// 営業日の判定に使うカレンダー (未設定の場合は calendar.Default)
+func (in AnalysisInput) BusinessCalendar() *calendar.Calendar {
+	if in.Calendar == nil {
+		return calendar.Default()
+	}
+	return in.Calendar
+}
+
+// 最新の基準価額 (1口あたり). 価格データがない場合は false を返す
+func (in AnalysisInput) LatestUnitPrice() (float64, bool) {
+	if len(in.HistoricalPrices) == 0 {
+		return 0, false
+	}
+	price := float64(in.HistoricalPrices[len(in.HistoricalPrices)-1].Price) / 10000.0
+	return price, price > 0
+}
+
+// 売却日に売却する口数と金額の見込みを計算できる戦略
+type Targeted interface {
+	Strategy
+	Target(input AnalysisInput) (units int, jpy float64)
+}
+
+// 今後の売却の見通しを示せる戦略
+type Projector interface {
+	Strategy
+	Projection(input AnalysisInput) []ProjectedSell
+}
+
+// 見通しの1回分の売却
+type ProjectedSell struct {
+	Date     time.Time
+	Fraction float64 // 評価額のうち売却する割合
+	Amount   float64 // 売却代金の見込み
+	Balance  float64 // 売却後の評価額の見込み
+}
+
+// 判断に使っている状態を表示できる戦略 (strategy status で表示する)
+type Reporter interface {
+	Strategy
+	Status(input AnalysisInput) string
 }
 
 // 売却判断アルゴリズムのインターフェース
 type Strategy interface {
 	Decide(input AnalysisInput) SellDecision
 }
+
+// date の時点で見えている取引と価格だけから判断の入力を組み立てる
+// 取引は日時の日付部分, 価格は日付で比較し, date 当日の分までを含める
+func InputAsOf(date time.Time, transactions []data.Transaction, prices []DailyPrice) AnalysisInput {
+	day := date.Format("2006-01-02")
+
+	var visibleTxs []data.Transaction
+	for _, tx := range transactions {
+		t, err := time.Parse(time.RFC3339, tx.Datetime)
+		if err != nil || t.In(date.Location()).Format("2006-01-02") > day {
+			continue
+		}
+		visibleTxs = append(visibleTxs, tx)
+	}
+
+	var visiblePrices []DailyPrice
+	for _, p := range prices {
+		if p.Date <= day {
+			visiblePrices = append(visiblePrices, p)
+		}
+	}
+
+	portfolio := data.ComputePortfolioStatus(visibleTxs)
+	if len(visiblePrices) > 0 {
+		portfolio.Valuate(visiblePrices[len(visiblePrices)-1].Price)
+	}
+	return AnalysisInput{
+		Date:             date,
+		Transactions:     visibleTxs,
+		HistoricalPrices: visiblePrices,
+		Portfolio:        portfolio,
+		Calendar:         calendar.Default(),
+	}
+}
@@ -0,0 +1,331 @@
+// internal/strategy/tax_ceiling.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/data"
+	"kk-invest/internal/schedule"
+	"math"
+	"strings"
+	"time"
+)
+
+// 年間の実現益の上限
+//
+// 他の戦略 (Inner) の売却判断を, 暦年ごとの実現益が AnnualCeiling を超えないように調整する
+// 扶養の範囲や国民健康保険料, 住民税の非課税の基準などを超えないようにするためのもの
+//   - cap: 上限までの残りを超える分の売却を見送る
+//   - spread: 上限までの残りを, その年の残りの売却日で等分した分までに抑える
+//
+// 年をまたいで売却を移すこともできる
+//   - 前倒し: その年の最後の売却日に上限まで余裕があれば, 翌年の売却日 PullForwardPeriods 回分までを前倒しで売却する
+//     前倒しした分は, 翌年の最初の売却日 (PullForwardPeriods 回分まで) の売却から差し引く
+//   - 繰り越し: 前年 12月の売却日に上限のため売却を減らした分を, その年の最初の売却日に上乗せする
+//
+// 実現益は取引履歴から移動平均法で計算する. 上限には他の所得を含まないため, 余裕を持った金額を指定すること
+type TaxCeilingStrategy struct {
+	Inner              Strategy
+	InnerName          string
+	AnnualCeiling      float64 // 暦年の実現益の上限 (円)
+	Mode               string  // cap または spread
+	TaxRate            float64 // 節税額の目安の計算に使う税率
+	PullForwardPeriods int     // 年末に前倒しする翌年の売却日の数 (0 で前倒ししない)
+	CarryOver          bool    // 前年 12月に見送った分を繰り越すか
+}
+
+func init() {
+	Register("tax_ceiling", "他の戦略の売却を, 暦年の実現益が上限を超えないように減らしたり, 年をまたいで移したりする (実現益の上限)", func() Strategy {
+		return NewTaxCeilingStrategy()
+	})
+}
+
+// 既定の引数で TaxCeilingStrategy を生成 (包む戦略は Configure で指定する)
+func NewTaxCeilingStrategy() *TaxCeilingStrategy {
+	return &TaxCeilingStrategy{
+		AnnualCeiling:      480000,
+		Mode:               "cap",
+		TaxRate:            0.20315,
+		PullForwardPeriods: 1,
+		CarryOver:          true,
+	}
+}
+
+func (s *TaxCeilingStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "inner", Type: ParamString, Default: "fixed_rate",
+			Description: "売却の判断に使う戦略の名前"},
+		{Name: "inner_params", Type: ParamString, Default: "",
+			Description: "inner の戦略の引数 (key=value をセミコロンで区切る, 例: annual_rate=0.05;schedule=weekly:fri). 設定ファイルの strategy_params の inner の戦略の値に重ねる"},
+		{Name: "annual_ceiling", Type: ParamFloat, Default: "480000", Min: bound(0),
+			Description: "暦年の実現益の上限 (円)"},
+		{Name: "mode", Type: ParamString, Default: "cap", Choices: []string{"cap", "spread"},
+			Description: "cap: 上限を超える分の売却を見送る, spread: 上限までの残りをその年の残りの売却日で等分する"},
+		{Name: "tax_rate", Type: ParamFloat, Default: "0.20315", Min: bound(0), Max: bound(1),
+			Description: "節税額の目安の計算に使う税率"},
+		{Name: "pull_forward_periods", Type: ParamInt, Default: "1", Min: bound(0), Max: bound(366),
+			Description: "年の最後の売却日に上限まで余裕がある場合, 翌年の売却日の何回分までを前倒しするか (0 で前倒ししない)"},
+		{Name: "carry_over", Type: ParamBool, Default: "true",
+			Description: "前年 12月に上限のため見送った分を, その年の最初の売却日に上乗せする"},
+	}
+}
+
+func (s *TaxCeilingStrategy) Configure(values ParamValues) error {
+	s.InnerName = values.String("inner")
+	if s.InnerName == "tax_ceiling" {
+		return fmt.Errorf("inner に tax_ceiling は指定できません")
+	}
+	var flags []string
+	for _, part := range strings.Split(values.String("inner_params"), ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			flags = append(flags, part)
+		}
+	}
+	params, err := ParseParamFlags(flags)
+	if err != nil {
+		return fmt.Errorf("inner_params: %w", err)
+	}
+	if s.Inner, _, err = Build(s.InnerName, configParams[s.InnerName], params); err != nil {
+		return fmt.Errorf("戦略 %s: %w", s.InnerName, err)
+	}
+
+	s.AnnualCeiling = values.Float("annual_ceiling")
+	s.Mode = values.String("mode")
+	s.TaxRate = values.Float("tax_rate")
+	s.PullForwardPeriods = values.Int("pull_forward_periods")
+	s.CarryOver = values.Bool("carry_over")
+	return nil
+}
+
+// 包んだ戦略の売却日の規則 (規則を持たない場合は nil)
+func (s *TaxCeilingStrategy) SellSchedule() *schedule.Schedule {
+	sched, _ := SellScheduleOf(s.Inner)
+	return sched
+}
+
+// year 年の実現益 (移動平均法)
+func realizedGains(input AnalysisInput, year int) float64 {
+	var status data.PortfolioStatus
+	gains := 0
+	for _, tx := range input.Transactions {
+		switch tx.Type {
+		case "buy":
+			status.ApplyBuy(tx.AmountJPY, tx.Units)
+		case "sell":
+			before := status.CostBasis
+			status.ApplySell(tx.AmountJPY, tx.Units)
+			if t, err := time.Parse(time.RFC3339, tx.Datetime); err == nil && t.In(input.Date.Location()).Year() == year {
+				gains += tx.AmountJPY - (before - status.CostBasis)
+			}
+		}
+	}
+	return float64(gains)
+}
+
+// 1口あたりの評価益 (最新の基準価額 - 1口あたりの取得原価)
+func gainPerUnit(input AnalysisInput) (float64, bool) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok || input.Portfolio.TotalUnits <= 0 {
+		return 0, false
+	}
+	return unitPrice - float64(input.Portfolio.CostBasis)/float64(input.Portfolio.TotalUnits), true
+}
+
+// date の日付に売却した口数
+func unitsSoldOn(input AnalysisInput, date time.Time) int {
+	day := date.Format("2006-01-02")
+	units := 0
+	for _, tx := range input.Transactions {
+		if tx.Type != "sell" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, tx.Datetime); err == nil && t.In(date.Location()).Format("2006-01-02") == day {
+			units += tx.Units
+		}
+	}
+	return units
+}
+
+// その年にまだ売却していないか
+func noSellsInYear(input AnalysisInput) bool {
+	for _, tx := range input.Transactions {
+		if tx.Type != "sell" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, tx.Datetime); err == nil && t.In(input.Date.Location()).Year() == input.Date.Year() {
+			return false
+		}
+	}
+	return true
+}
+
+// 前年 12月の売却日に上限のため見送ったとみられる口数
+// 前年の実現益に今の売却1回分の評価益を加えると上限を超える場合, 12月の売却日ごとに今の売却1回分に足りなかった口数を合計する
+func (s *TaxCeilingStrategy) carriedUnits(input AnalysisInput, sched *schedule.Schedule, perPeriod int, gain float64) int {
+	lastYear := input.Date.Year() - 1
+	if realizedGains(input, lastYear)+float64(perPeriod)*gain <= s.AnnualCeiling {
+		return 0
+	}
+	from := time.Date(lastYear, time.December, 1, 0, 0, 0, 0, input.Date.Location())
+	to := time.Date(lastYear, time.December, 31, 0, 0, 0, 0, input.Date.Location())
+	units := 0
+	for _, day := range sched.Upcoming(from, 31, input.BusinessCalendar()) {
+		if day.After(to) {
+			break
+		}
+		units += max(perPeriod-unitsSoldOn(input, day), 0)
+	}
+	return units
+}
+
+// 前年の最後の売却日に前倒しで売却したとみられる口数のうち, まだ差し引いていない口数
+// 前年の最後の売却日に今の売却1回分を超えて売却した口数から, その年のこれまでの売却日に売却1回分に足りなかった口数を除く
+// 翌年の売却日 PullForwardPeriods 回分を過ぎた後は差し引かない
+func (s *TaxCeilingStrategy) pulledUnits(input AnalysisInput, sched *schedule.Schedule, perPeriod int) int {
+	cal := input.BusinessCalendar()
+	jan1 := time.Date(input.Date.Year(), time.January, 1, 0, 0, 0, 0, input.Date.Location())
+	last := sched.Previous(jan1, jan1.AddDate(-1, 0, 0), 1, cal)
+	if len(last) == 0 {
+		return 0
+	}
+	prev := sched.Previous(input.Date, jan1, s.PullForwardPeriods, cal)
+	if len(prev) >= s.PullForwardPeriods {
+		return 0
+	}
+	units := unitsSoldOn(input, last[0]) - perPeriod
+	for _, day := range prev {
+		units -= max(perPeriod-unitsSoldOn(input, day), 0)
+	}
+	return max(units, 0)
+}
+
+// 包んだ戦略の判断を調整した口数と, その説明
+func (s *TaxCeilingStrategy) adjust(input AnalysisInput, units int) (int, []string) {
+	gain, ok := gainPerUnit(input)
+	if !ok {
+		return units, nil
+	}
+	year := input.Date.Year()
+	ytd := realizedGains(input, year)
+	room := math.Max(s.AnnualCeiling-ytd, 0)
+	notes := []string{fmt.Sprintf("%d年の実現益: %.0f 円 (上限 %.0f 円まで残り %.0f 円), 1万口あたりの評価益: %.0f 円", year, ytd, s.AnnualCeiling, room, gain*10000)}
+	if gain <= 0 {
+		notes = append(notes, "評価益がないため, 売却による実現益は発生しません")
+		return units, notes
+	}
+
+	cal := input.BusinessCalendar()
+	sched, scheduled := SellScheduleOf(s.Inner)
+	targeted, hasTarget := s.Inner.(Targeted)
+	perPeriod := 0
+	if hasTarget {
+		perPeriod, _ = targeted.Target(input)
+	}
+
+	// 前年 12月に見送った分の繰り越し
+	if s.CarryOver && scheduled && perPeriod > 0 && noSellsInYear(input) {
+		if carried := s.carriedUnits(input, sched, perPeriod, gain); carried > 0 {
+			units += carried
+			notes = append(notes, fmt.Sprintf("前年 12月に上限のため見送った %d口を上乗せします", carried))
+		}
+	}
+
+	// 前年の最後の売却日に前倒しした分を差し引く
+	if s.PullForwardPeriods > 0 && scheduled && perPeriod > 0 && units > 0 {
+		if pulled := min(s.pulledUnits(input, sched, perPeriod), units); pulled > 0 {
+			units -= pulled
+			notes = append(notes, fmt.Sprintf("前年の最後の売却日に前倒しした %d口を差し引きます", pulled))
+		}
+	}
+
+	// 上限を超える分の売却を見送る
+	allowance := room
+	if s.Mode == "spread" && scheduled {
+		end := time.Date(year, time.December, 31, 0, 0, 0, 0, input.Date.Location())
+		if n := sched.CountBetween(input.Date, end, cal); n > 0 {
+			allowance = room / float64(n)
+			notes = append(notes, fmt.Sprintf("残り %.0f 円を今年の残りの売却日 %d回で等分し, 今回は %.0f 円までとします", room, n, allowance))
+		}
+	}
+	if limit := int(allowance / gain); units > limit {
+		excess := float64(units-limit) * gain
+		notes = append(notes, fmt.Sprintf("売却を %d口から %d口に減らし, 今年の実現益を %.0f 円抑えます (税率 %.3f%% で約 %.0f 円)",
+			units, limit, excess, s.TaxRate*100, excess*s.TaxRate))
+		units = limit
+	}
+
+	// 年の最後の売却日に上限まで余裕があれば, 翌年分を前倒しする
+	if s.PullForwardPeriods > 0 && scheduled && perPeriod > 0 {
+		next, found := sched.Next(input.Date.AddDate(0, 0, 1), cal)
+		left := room - float64(units)*gain
+		if (!found || next.Year() > year) && left >= gain {
+			extra := min(int(left/gain), perPeriod*s.PullForwardPeriods)
+			moved := float64(extra) * gain
+			notes = append(notes, fmt.Sprintf("今年の上限まで余裕があるため, 翌年の売却 %d口を前倒しします. 翌年の実現益を %.0f 円減らします (税率 %.3f%% で約 %.0f 円)",
+				extra, moved, s.TaxRate*100, moved*s.TaxRate))
+			units += extra
+		}
+	}
+	return min(units, input.Portfolio.TotalUnits), notes
+}
+
+// 売却日に売却する口数と金額の見込み (包んだ戦略の見込みを, その年の上限までの残りで抑えたもの)
+func (s *TaxCeilingStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	targeted, ok := s.Inner.(Targeted)
+	unitPrice, priced := input.LatestUnitPrice()
+	if !ok || !priced {
+		return 0, 0
+	}
+	units, _ = targeted.Target(input)
+	if gain, ok := gainPerUnit(input); ok && gain > 0 {
+		room := math.Max(s.AnnualCeiling-realizedGains(input, input.Date.Year()), 0)
+		units = min(units, int(room/gain))
+	}
+	return units, float64(units) * unitPrice
+}
+
+func (s *TaxCeilingStrategy) Decide(input AnalysisInput) SellDecision {
+	decision := s.Inner.Decide(input)
+	// 包んだ戦略が売却しないと判断した場合 (売却日でない, 最小注文金額に満たないなど) は, 繰り越しや前倒しで売却を作らない
+	if !decision.ShouldSell {
+		return decision
+	}
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return decision
+	}
+
+	adjusted, notes := s.adjust(input, decision.UnitsToSell)
+	reason := decision.Reason
+	if len(notes) > 0 {
+		reason += "\n💴 実現益の上限 (" + s.Mode + ")"
+		for _, n := range notes {
+			reason += "\n  - " + n
+		}
+	}
+	if adjusted <= 0 {
+		return SellDecision{Reason: reason}
+	}
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      adjusted,
+		ExpectedProceeds: float64(adjusted) * unitPrice,
+		Reason:           reason,
+	}
+}
+
+// その年の実現益と上限, 包んだ戦略の状態
+func (s *TaxCeilingStrategy) Status(input AnalysisInput) string {
+	var b strings.Builder
+	year := input.Date.Year()
+	ytd := realizedGains(input, year)
+	fmt.Fprintf(&b, "包んだ戦略: %s\n", s.InnerName)
+	fmt.Fprintf(&b, "%d年の実現益: %.0f 円 (上限: %.0f 円, 残り: %.0f 円)\n", year, ytd, s.AnnualCeiling, math.Max(s.AnnualCeiling-ytd, 0))
+	fmt.Fprintf(&b, "%d年の実現益: %.0f 円\n", year-1, realizedGains(input, year-1))
+	if gain, ok := gainPerUnit(input); ok {
+		fmt.Fprintf(&b, "1万口あたりの評価益: %.0f 円\n", gain*10000)
+	}
+	if r, ok := s.Inner.(Reporter); ok {
+		b.WriteString(r.Status(input))
+	}
+	return b.String()
+}
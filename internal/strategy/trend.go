@@ -0,0 +1,298 @@
+// internal/strategy/trend.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/schedule"
+	"math"
+	"sort"
+	"time"
+)
+
+// 移動平均による売却の繰り延べ
+//
+// 売却日ごとに評価額の年率 AnnualRate を売却日の数で等分した金額 (1回分) を売却する
+// 基準価額が移動平均を Band 以上下回っている売却日は, 1回分の BelowFactor 倍だけを売却し, 残りを繰り延べる
+// 基準価額が移動平均まで戻った後の売却日には, 繰り延べた分を CatchUpPeriods 回に分けて上乗せして売却する
+//
+// 繰り延べの状態は, 過去の売却日ごとの基準価額と移動平均を価格履歴からたどり直して求める
+// 繰り延べは MaxDeferred 回分までで, それを超える分は取り戻さない
+type TrendStrategy struct {
+	AnnualRate     float64            // 1年間に売却する評価額の割合
+	Schedule       *schedule.Schedule // 売却日の規則
+	Average        string             // 移動平均の種類 (sma または ema)
+	Window         int                // 移動平均の期間 (記録された基準価額の日数)
+	Band           float64            // 基準価額が移動平均をこの割合以上下回ったら下落中とみなす
+	BelowFactor    float64            // 下落中の売却日に売却する 1回分に対する割合
+	CatchUpPeriods int                // 繰り延べた分を取り戻す売却日の数
+	MaxDeferred    float64            // 繰り延べる上限 (1回分に対する倍数)
+	MinOrderJPY    float64            // 売却代金の見込みがこの金額に満たない場合は売却しない
+}
+
+func init() {
+	Register("trend", "基準価額が移動平均を下回っている間は売却を減らして繰り延べ, 移動平均まで戻ったら繰り延べた分を取り戻す", func() Strategy {
+		return NewTrendStrategy()
+	})
+}
+
+// 既定の引数で TrendStrategy を生成
+func NewTrendStrategy() *TrendStrategy {
+	return &TrendStrategy{
+		AnnualRate:     0.04,
+		Schedule:       schedule.Weekly(time.Sunday),
+		Average:        "sma",
+		Window:         200,
+		CatchUpPeriods: 4,
+		MaxDeferred:    12,
+		MinOrderJPY:    100,
+	}
+}
+
+func (s *TrendStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "annual_rate", Type: ParamFloat, Default: "0.04", Min: bound(0.001), Max: bound(1),
+			Description: "1年間に売却する評価額の割合 (売却日の数で等分した金額を 1回分とする)"},
+		scheduleParam("weekly:sun", ""),
+		{Name: "average", Type: ParamString, Default: "sma", Choices: []string{"sma", "ema"},
+			Description: "移動平均の種類 (sma: 単純移動平均, ema: 指数移動平均)"},
+		{Name: "window", Type: ParamInt, Default: "200", Min: bound(2), Max: bound(2000),
+			Description: "移動平均の期間 (記録された基準価額の日数)"},
+		{Name: "band", Type: ParamFloat, Default: "0", Min: bound(0), Max: bound(0.5),
+			Description: "基準価額が移動平均をこの割合以上下回ったら下落中とみなす"},
+		{Name: "below_factor", Type: ParamFloat, Default: "0", Min: bound(0), Max: bound(1),
+			Description: "下落中の売却日に売却する 1回分に対する割合 (0 で全額を繰り延べる)"},
+		{Name: "catchup_periods", Type: ParamInt, Default: "4", Min: bound(1), Max: bound(100),
+			Description: "繰り延べた分を何回の売却日に分けて取り戻すか"},
+		{Name: "max_deferred", Type: ParamFloat, Default: "12", Min: bound(0), Max: bound(100),
+			Description: "繰り延べる上限 (1回分の何回分か). 0 で繰り延べた分を取り戻さない"},
+		{Name: "min_order_jpy", Type: ParamFloat, Default: "100", Min: bound(0),
+			Description: "最小注文金額 (円). 売却代金の見込みがこれに満たない場合は売却しない"},
+	}
+}
+
+func (s *TrendStrategy) Configure(values ParamValues) error {
+	sched, err := schedule.Parse(values.String("schedule"))
+	if err != nil {
+		return fmt.Errorf("売却日の規則: %w", err)
+	}
+	s.Schedule = sched
+	s.AnnualRate = values.Float("annual_rate")
+	s.Average = values.String("average")
+	s.Window = values.Int("window")
+	s.Band = values.Float("band")
+	s.BelowFactor = values.Float("below_factor")
+	s.CatchUpPeriods = values.Int("catchup_periods")
+	s.MaxDeferred = values.Float("max_deferred")
+	s.MinOrderJPY = values.Float("min_order_jpy")
+	return nil
+}
+
+func (s *TrendStrategy) SellSchedule() *schedule.Schedule {
+	return s.Schedule
+}
+
+// 売却日 1日分の判断
+type trendStep struct {
+	price, average float64
+	known          bool    // 移動平均を計算できるだけの価格履歴があるか
+	down           bool    // 下落中か
+	periods        float64 // 売却する量 (1回分に対する倍数)
+	deferred       float64 // この売却日の後に繰り延べている量
+}
+
+// 繰り延べの状態
+type trendState struct {
+	deferred  float64
+	remaining int // 繰り延べた分を取り戻す残りの売却日の数
+}
+
+// 1つの売却日を進める
+func (s *TrendStrategy) step(state *trendState, step *trendStep) {
+	step.periods = 1
+	if step.down {
+		step.periods = s.BelowFactor
+		state.deferred = math.Min(state.deferred+1-s.BelowFactor, s.MaxDeferred)
+		state.remaining = s.CatchUpPeriods
+	} else if state.deferred > 0 {
+		extra := state.deferred / float64(max(state.remaining, 1))
+		step.periods += extra
+		state.deferred -= extra
+		state.remaining--
+		if state.remaining <= 0 {
+			state.deferred = 0
+		}
+	}
+	step.deferred = state.deferred
+}
+
+// 繰り延べをたどり直す過去の売却日の数
+// 上限まで繰り延べるのに必要な回数と, それを取り戻す回数を合わせたもの
+func (s *TrendStrategy) replayLength() int {
+	if s.BelowFactor >= 1 || s.MaxDeferred <= 0 {
+		return 0
+	}
+	return int(math.Ceil(s.MaxDeferred/(1-s.BelowFactor))) + s.CatchUpPeriods
+}
+
+// input.Date を売却日とした場合の判断
+func (s *TrendStrategy) evaluate(input AnalysisInput) trendStep {
+	prices := input.HistoricalPrices
+
+	// 判断の基準日より前の売却日を, 新しいものから replayLength 回分集める
+	var days []time.Time
+	if n := s.replayLength(); n > 0 && len(prices) > 0 {
+		first, _ := time.ParseInLocation("2006-01-02", prices[0].Date, input.Date.Location())
+		days = s.Schedule.Previous(input.Date, first, n, input.BusinessCalendar())
+	}
+
+	// 移動平均の計算に必要な範囲だけを切り出す
+	lastIndex := func(d time.Time) int {
+		key := d.Format("2006-01-02")
+		return sort.Search(len(prices), func(i int) bool { return prices[i].Date > key }) - 1
+	}
+	from := len(prices)
+	if len(days) > 0 {
+		from = lastIndex(days[len(days)-1]) + 1
+	}
+	from = max(from-1, 0)
+	averages := s.movingAverages(prices, from)
+
+	observe := func(d time.Time) trendStep {
+		i := lastIndex(d)
+		if i < from || i >= len(prices) {
+			return trendStep{}
+		}
+		avg := averages[i-from]
+		st := trendStep{price: float64(prices[i].Price), average: avg, known: !math.IsNaN(avg)}
+		st.down = st.known && st.price < avg*(1-s.Band)
+		return st
+	}
+
+	var state trendState
+	for i := len(days) - 1; i >= 0; i-- {
+		st := observe(days[i])
+		s.step(&state, &st)
+	}
+	today := observe(input.Date)
+	s.step(&state, &today)
+	return today
+}
+
+// prices[from:] の各日の移動平均 (期間に満たない日は NaN)
+// ema は from より Window の 3倍前から計算を始め, 最初の値を種にする
+func (s *TrendStrategy) movingAverages(prices []DailyPrice, from int) []float64 {
+	out := make([]float64, len(prices)-from)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	if s.Average == "ema" {
+		start := max(from-3*s.Window, 0)
+		if len(prices)-start < s.Window {
+			return out
+		}
+		alpha := 2 / float64(s.Window+1)
+		ema := float64(prices[start].Price)
+		for i := start + 1; i < len(prices); i++ {
+			ema += alpha * (float64(prices[i].Price) - ema)
+			if i >= from && i+1 >= s.Window {
+				out[i-from] = ema
+			}
+		}
+		return out
+	}
+
+	start := max(from-s.Window+1, 0)
+	sum := 0.0
+	for i := start; i < len(prices); i++ {
+		sum += float64(prices[i].Price)
+		if i-start >= s.Window {
+			sum -= float64(prices[i-s.Window].Price)
+		}
+		if i >= from && i-start+1 >= s.Window {
+			out[i-from] = sum / float64(s.Window)
+		}
+	}
+	return out
+}
+
+// 1回分の売却代金 (評価額の年率を, その年の売却日の数で等分した金額)
+func (s *TrendStrategy) baseAmount(input AnalysisInput) float64 {
+	unitPrice, ok := input.LatestUnitPrice()
+	n := s.Schedule.CountInYear(input.Date.Year(), input.BusinessCalendar())
+	if !ok || n == 0 {
+		return 0
+	}
+	return float64(input.Portfolio.TotalUnits) * unitPrice * s.AnnualRate / float64(n)
+}
+
+// 売却日に売却する口数と金額の見込み
+// 1回分の金額に移動平均による倍率を掛け, 最新の基準価額で口数に換算して切り捨てる
+func (s *TrendStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	return s.target(input, s.evaluate(input))
+}
+
+func (s *TrendStrategy) target(input AnalysisInput, st trendStep) (units int, jpy float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	units = min(int(s.baseAmount(input)*st.periods/unitPrice), input.Portfolio.TotalUnits)
+	return units, float64(units) * unitPrice
+}
+
+func (s *TrendStrategy) Decide(input AnalysisInput) SellDecision {
+	st := s.evaluate(input)
+	units, jpy := s.target(input, st)
+
+	if !s.Schedule.Is(input.Date, input.BusinessCalendar()) {
+		reason, next, ok := notSellDayReason(input, s.Schedule)
+		if ok && units > 0 {
+			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円, 現在の基準価額の場合)", next.Format("2006-01-02"), units, jpy)
+		}
+		return SellDecision{Reason: reason}
+	}
+
+	if _, ok := input.LatestUnitPrice(); !ok {
+		return SellDecision{Reason: "過去の価格データがありません"}
+	}
+	if input.Portfolio.TotalUnits <= 0 {
+		return SellDecision{Reason: "保有口数がありません"}
+	}
+
+	name := map[string]string{"sma": "単純移動平均", "ema": "指数移動平均"}[s.Average]
+	var trend string
+	switch {
+	case !st.known:
+		trend = fmt.Sprintf("価格履歴が %d日分に満たないため, %s%d日は計算できません", s.Window, name, s.Window)
+	case st.down && s.BelowFactor == 0:
+		trend = fmt.Sprintf("基準価額 %.0f が%s%d日 (%.0f) を下回っているため, 売却を見送って繰り延べます",
+			st.price, name, s.Window, st.average)
+	case st.down:
+		trend = fmt.Sprintf("基準価額 %.0f が%s%d日 (%.0f) を下回っているため, 1回分の %.0f%% を売却し, 残りを繰り延べます",
+			st.price, name, s.Window, st.average, s.BelowFactor*100)
+	default:
+		trend = fmt.Sprintf("基準価額 %.0f は%s%d日 (%.0f) を下回っていません", st.price, name, s.Window, st.average)
+		if st.periods > 1 {
+			trend += fmt.Sprintf(". 繰り延べた分のうち %.2f回分を上乗せします", st.periods-1)
+		}
+	}
+	if st.deferred > 0 {
+		trend += fmt.Sprintf(" (繰り延べの残り: %.2f回分)", st.deferred)
+	}
+	trend = fmt.Sprintf("%s は売却日です. %s", input.Date.Format("2006-01-02"), trend)
+
+	if st.periods == 0 {
+		return SellDecision{Reason: trend}
+	}
+	if units < 1 || jpy < s.MinOrderJPY {
+		return SellDecision{
+			Reason: fmt.Sprintf("%s\n売却代金の見込み (%.0f 円) が最小注文金額 (%.0f 円) に満たないため, 売却しません", trend, jpy, s.MinOrderJPY),
+		}
+	}
+	return SellDecision{
+		ShouldSell:       true,
+		UnitsToSell:      units,
+		ExpectedProceeds: jpy,
+		Reason:           fmt.Sprintf("%s\n%d口 (%.0f 円) を売却します", trend, units, jpy),
+	}
+}
@@ -0,0 +1,194 @@
+// internal/strategy/vpw.go
+package strategy
+
+import (
+	"fmt"
+	"kk-invest/internal/schedule"
+	"math"
+	"time"
+)
+
+// 期限付きの変動率取り崩し (VPW: Variable Percentage Withdrawal)
+// 期限までの残りの売却回数と想定利回りから, 評価額がちょうど期限に尽きるよう毎回の売却割合を決める
+type VPWStrategy struct {
+	Horizon        time.Time          // 保有資産を使い切る期限
+	ExpectedReturn float64            // 想定利回り (年率)
+	Schedule       *schedule.Schedule // 売却日の規則
+	MinOrderJPY    float64            // 売却代金の見込みがこの金額に満たない場合は売却しない
+}
+
+func init() {
+	Register("vpw", "期限 (例: 95歳) までに保有資産を使い切るよう, 残りの売却回数と想定利回りから毎回の売却割合を決める (変動率取り崩し)", func() Strategy {
+		return NewVPWStrategy()
+	})
+}
+
+// 既定の引数で VPWStrategy を生成 (期限は設定が必要)
+func NewVPWStrategy() *VPWStrategy {
+	sched, _ := schedule.Parse("bizday:1")
+	return &VPWStrategy{
+		ExpectedReturn: 0.03,
+		Schedule:       sched,
+		MinOrderJPY:    100,
+	}
+}
+
+func (s *VPWStrategy) Params() []ParamSpec {
+	return []ParamSpec{
+		{Name: "horizon", Type: ParamString, Default: "",
+			Description: "保有資産を使い切る期限 (YYYY-MM-DD). 省略時は birth_date と target_age から計算する"},
+		{Name: "birth_date", Type: ParamString, Default: "",
+			Description: "生年月日 (YYYY-MM-DD)"},
+		{Name: "target_age", Type: ParamInt, Default: "95", Min: bound(1), Max: bound(130),
+			Description: "保有資産を使い切る年齢 (birth_date とあわせて指定する)"},
+		{Name: "expected_return", Type: ParamFloat, Default: "0.03", Min: bound(-0.5), Max: bound(1),
+			Description: "想定利回り (年率)"},
+		scheduleParam("bizday:1", ""),
+		{Name: "min_order_jpy", Type: ParamFloat, Default: "100", Min: bound(0),
+			Description: "最小注文金額 (円). 売却代金の見込みがこれに満たない場合は売却しない"},
+	}
+}
+
+func (s *VPWStrategy) Configure(values ParamValues) error {
+	sched, err := schedule.Parse(values.String("schedule"))
+	if err != nil {
+		return fmt.Errorf("売却日の規則: %w", err)
+	}
+	s.Schedule = sched
+	s.ExpectedReturn = values.Float("expected_return")
+	s.MinOrderJPY = values.Float("min_order_jpy")
+
+	switch {
+	case values.String("horizon") != "":
+		if s.Horizon, err = time.ParseInLocation("2006-01-02", values.String("horizon"), time.Local); err != nil {
+			return fmt.Errorf("horizon の日付が不正です: %q", values.String("horizon"))
+		}
+	case values.String("birth_date") != "":
+		birth, err := time.ParseInLocation("2006-01-02", values.String("birth_date"), time.Local)
+		if err != nil {
+			return fmt.Errorf("birth_date の日付が不正です: %q", values.String("birth_date"))
+		}
+		s.Horizon = birth.AddDate(values.Int("target_age"), 0, 0)
+	default:
+		return fmt.Errorf("horizon または birth_date を指定してください")
+	}
+	return nil
+}
+
+func (s *VPWStrategy) SellSchedule() *schedule.Schedule {
+	return s.Schedule
+}
+
+// 1回の売却日あたりの想定利回り
+func (s *VPWStrategy) periodReturn(date time.Time, input AnalysisInput) float64 {
+	n := s.Schedule.CountInYear(date.Year(), input.BusinessCalendar())
+	if n == 0 {
+		return 0
+	}
+	return math.Pow(1+s.ExpectedReturn, 1/float64(n)) - 1
+}
+
+// 残り n 回の売却で評価額を使い切るための, 今回の売却割合
+// 売却後の残高が1回あたり r で増える前提で, 毎回同じ金額を受け取れる割合 (期首払いの年金現価係数の逆数)
+func vpwFraction(r float64, n int) float64 {
+	if n <= 1 {
+		return 1
+	}
+	if r == 0 {
+		return 1 / float64(n)
+	}
+	return r / ((1 + r) * (1 - math.Pow(1+r, -float64(n))))
+}
+
+// date の売却日から期限までの売却回数 (date を含む)
+func (s *VPWStrategy) remaining(date time.Time, input AnalysisInput) int {
+	if date.After(s.Horizon) {
+		return 0
+	}
+	return s.Schedule.CountBetween(date, s.Horizon, input.BusinessCalendar())
+}
+
+// 売却日に売却する口数と金額の見込み
+func (s *VPWStrategy) Target(input AnalysisInput) (units int, jpy float64) {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok {
+		return 0, 0
+	}
+	date := input.Date
+	if !s.Schedule.Is(date, input.BusinessCalendar()) {
+		next, ok := s.Schedule.Next(date, input.BusinessCalendar())
+		if !ok {
+			return 0, 0
+		}
+		date = next
+	}
+	fraction := 1.0
+	if n := s.remaining(date, input); n > 0 {
+		fraction = vpwFraction(s.periodReturn(date, input), n)
+	}
+	units = min(int(float64(input.Portfolio.TotalUnits)*fraction), input.Portfolio.TotalUnits)
+	return units, float64(units) * unitPrice
+}
+
+// 想定利回りどおりに推移した場合の, 期限までの売却の見通し
+func (s *VPWStrategy) Projection(input AnalysisInput) []ProjectedSell {
+	unitPrice, ok := input.LatestUnitPrice()
+	if !ok || input.Portfolio.TotalUnits <= 0 {
+		return nil
+	}
+	cal := input.BusinessCalendar()
+	balance := float64(input.Portfolio.TotalUnits) * unitPrice
+	days := s.Schedule.Upcoming(input.Date, s.remaining(input.Date, input), cal)
+
+	var out []ProjectedSell
+	for i, day := range days {
+		if i > 0 {
+			balance *= 1 + s.periodReturn(day, input)
+		}
+		fraction := vpwFraction(s.periodReturn(day, input), len(days)-i)
+		amount := balance * fraction
+		balance -= amount
+		out = append(out, ProjectedSell{Date: day, Fraction: fraction, Amount: amount, Balance: balance})
+	}
+	return out
+}
+
+func (s *VPWStrategy) Decide(input AnalysisInput) SellDecision {
+	units, jpy := s.Target(input)
+
+	if !s.Schedule.Is(input.Date, input.BusinessCalendar()) {
+		reason, next, ok := notSellDayReason(input, s.Schedule)
+		if ok && units > 0 {
+			reason += fmt.Sprintf("\n次回の売却予定日: %s \n売却予定口数: %d口 (%.0f 円)", next.Format("2006-01-02"), units, jpy)
+		}
+		return SellDecision{Reason: reason}
+	}
+
+	if _, ok := input.LatestUnitPrice(); !ok {
+		return SellDecision{Reason: "過去の価格データがありません"}
+	}
+	if input.Portfolio.TotalUnits <= 0 {
+		return SellDecision{Reason: "保有口数がありません"}
+	}
+
+	n := s.remaining(input.Date, input)
+	reason := fmt.Sprintf("%s は売却日です. 期限 %s までの残り %d回 (想定利回り 年 %.2f%%) で使い切るため, 評価額の %.2f%% にあたる %d口 (%.0f 円) を売却します",
+		input.Date.Format("2006-01-02"), s.Horizon.Format("2006-01-02"), n, s.ExpectedReturn*100,
+		vpwFraction(s.periodReturn(input.Date, input), n)*100, units, jpy)
+	if n == 0 {
+		reason = fmt.Sprintf("%s は期限 %s を過ぎているため, 保有口数の全て (%d口, %.0f 円) を売却します",
+			input.Date.Format("2006-01-02"), s.Horizon.Format("2006-01-02"), units, jpy)
+	}
+	if jpy < s.MinOrderJPY && units < input.Portfolio.TotalUnits {
+		return SellDecision{
+			Reason: fmt.Sprintf("売却代金の見込み (%.0f 円) が最小注文金額 (%.0f 円) に満たないため, 売却しません", jpy, s.MinOrderJPY),
+		}
+	}
+
+	return SellDecision{
+		ShouldSell:       units > 0,
+		UnitsToSell:      units,
+		ExpectedProceeds: jpy,
+		Reason:           reason,
+	}
+}